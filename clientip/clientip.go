@@ -0,0 +1,170 @@
+// Package clientip resolves the IP address of the client of an HTTP request behind
+// trusted reverse proxies.
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// DefaultTrustedProxies are the loopback, link-local and private networks, where the
+// load balancers and the ingress controllers usually run.
+var DefaultTrustedProxies = []string{
+	"127.0.0.0/8",
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"169.254.0.0/16",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+}
+
+// Config handles Resolver configuration.
+type Config struct {
+	// TrustedProxies are the CIDRs of the proxies whose forwarding headers are trusted.
+	// Defaults to DefaultTrustedProxies, set an empty slice to trust none.
+	TrustedProxies []string
+}
+
+// Resolver returns the client IP address of the requests. The forwarding headers are
+// only read when the request comes from a trusted proxy, and they are walked from
+// right to left, skipping the trusted proxies, so a client cannot spoof its address.
+// The Forwarded header (RFC 7239) takes precedence over X-Forwarded-For, and
+// X-Real-IP is used when none of them is set.
+//
+// A nil Resolver uses the default configuration.
+type Resolver struct {
+	trusted []netip.Prefix
+}
+
+var defaultResolver = MustNew(Config{})
+
+// New creates a Resolver with the given configuration.
+func New(config Config) (*Resolver, error) {
+	if config.TrustedProxies == nil {
+		config.TrustedProxies = DefaultTrustedProxies
+	}
+	r := &Resolver{}
+	for _, cidr := range config.TrustedProxies {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(cidr)
+			if addrErr != nil {
+				return nil, fmt.Errorf("clientip: invalid trusted proxy %q: %w", cidr, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		r.trusted = append(r.trusted, prefix.Masked())
+	}
+	return r, nil
+}
+
+// MustNew is like New but panics if the configuration is invalid.
+func MustNew(config Config) *Resolver {
+	r, err := New(config)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// ClientIP returns the client IP address of the request using the default configuration.
+func ClientIP(r *http.Request) string {
+	return defaultResolver.ClientIP(r)
+}
+
+// ClientIP returns the client IP address of the request, or an empty string if it is unknown.
+func (res *Resolver) ClientIP(r *http.Request) string {
+	if res == nil {
+		res = defaultResolver
+	}
+	if r == nil {
+		return ""
+	}
+	remote, ok := parseAddr(r.RemoteAddr)
+	if !ok {
+		return ""
+	}
+	if !res.isTrusted(remote) {
+		return remote.String()
+	}
+	hops := forwardedFor(r.Header.Values("Forwarded"))
+	if len(hops) == 0 {
+		hops = xForwardedFor(r.Header.Values("X-Forwarded-For"))
+	}
+	if len(hops) == 0 {
+		if ip, ok := parseAddr(r.Header.Get("X-Real-IP")); ok {
+			return ip.String()
+		}
+		return remote.String()
+	}
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip, ok := parseAddr(hops[i])
+		if !ok {
+			// Obfuscated or unknown hop, the previous one is the closest known address.
+			break
+		}
+		client = ip
+		if !res.isTrusted(ip) {
+			break
+		}
+	}
+	return client.String()
+}
+
+func (res *Resolver) isTrusted(ip netip.Addr) bool {
+	for _, p := range res.trusted {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor returns the for parameters of the Forwarded headers, in order.
+func forwardedFor(values []string) []string {
+	var hops []string
+	for _, v := range values {
+		for _, element := range strings.Split(v, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(key, "for") {
+					hops = append(hops, strings.Trim(value, `"`))
+				}
+			}
+		}
+	}
+	return hops
+}
+
+// xForwardedFor returns the addresses of the X-Forwarded-For headers, in order.
+func xForwardedFor(values []string) []string {
+	var hops []string
+	for _, v := range values {
+		for _, hop := range strings.Split(v, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	return hops
+}
+
+// parseAddr parses an IP address with an optional port, like "192.0.2.1:8080" or
+// "[2001:db8::1]:8080".
+func parseAddr(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	addr, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
@@ -0,0 +1,46 @@
+package clientip
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name   string
+		remote string
+		header http.Header
+		want   string
+	}{
+		{"no proxy", "203.0.113.7:5000", nil, "203.0.113.7"},
+		{"untrusted proxy", "203.0.113.7:5000", http.Header{"X-Forwarded-For": {"198.51.100.1"}}, "203.0.113.7"},
+		{"spoofed hop", "10.0.0.2:5000", http.Header{"X-Forwarded-For": {"1.1.1.1, 198.51.100.1, 10.0.0.3"}}, "198.51.100.1"},
+		{"multiple headers", "10.0.0.2:5000", http.Header{"X-Forwarded-For": {"1.1.1.1", "198.51.100.1"}}, "198.51.100.1"},
+		{"all trusted", "127.0.0.1:5000", http.Header{"X-Forwarded-For": {"10.0.0.5, 10.0.0.3"}}, "10.0.0.5"},
+		{"remote without port", "10.132.0.241", http.Header{"X-Forwarded-For": {"188.26.219.97, 10.132.0.241"}}, "188.26.219.97"},
+		{"forwarded", "10.0.0.2:5000", http.Header{
+			"Forwarded":       {`for=1.1.1.1, for="[2001:db8:cafe::17]:4711";proto=https`},
+			"X-Forwarded-For": {"198.51.100.1"},
+		}, "2001:db8:cafe::17"},
+		{"obfuscated hop", "10.0.0.2:5000", http.Header{"Forwarded": {"for=_hidden, for=10.0.0.9"}}, "10.0.0.9"},
+		{"real ip", "10.0.0.2:5000", http.Header{"X-Real-Ip": {"198.51.100.1"}}, "198.51.100.1"},
+		{"ipv4 mapped", "[::ffff:203.0.113.7]:5000", nil, "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &http.Request{RemoteAddr: tt.remote, Header: tt.header}
+			assert.Equal(t, tt.want, ClientIP(r))
+		})
+	}
+}
+
+func TestTrustedProxies(t *testing.T) {
+	r := &http.Request{RemoteAddr: "10.0.0.2:5000", Header: http.Header{"X-Forwarded-For": {"198.51.100.1"}}}
+	assert.Equal(t, "10.0.0.2", MustNew(Config{TrustedProxies: []string{}}).ClientIP(r))
+	assert.Equal(t, "198.51.100.1", MustNew(Config{TrustedProxies: []string{"10.0.0.2"}}).ClientIP(r))
+
+	_, err := New(Config{TrustedProxies: []string{"10.0.0.0/33"}})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,234 @@
+package obs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/compute/metadata"
+	"github.com/JoinVerse/obs/errtrack"
+	"github.com/JoinVerse/obs/logformat"
+	"github.com/getsentry/sentry-go"
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variables read by ConfigFromEnv and LoadConfig.
+const (
+	EnvSentryDSN          = "OBS_SENTRY_DSN"
+	EnvServiceName        = "OBS_SERVICE_NAME"
+	EnvServiceVersion     = "OBS_SERVICE_VERSION"
+	EnvLogLevel           = "OBS_LOG_LEVEL"
+	EnvLogFormat          = logformat.EnvVar
+	EnvGCloudDisabled     = "OBS_GCLOUD_DISABLED"
+	EnvTracingExporter    = "OBS_TRACING_EXPORTER"
+	EnvTracingEndpoint    = "OBS_TRACING_ENDPOINT"
+	EnvTracingInsecure    = "OBS_TRACING_INSECURE"
+	EnvTracingSampleRatio = "OBS_TRACING_SAMPLE_RATIO"
+)
+
+// FileConfig is the content of the files read by LoadConfig.
+type FileConfig struct {
+	ServiceName     string            `json:"serviceName" yaml:"serviceName"`
+	ServiceVersion  string            `json:"serviceVersion" yaml:"serviceVersion"`
+	GCloudProjectID string            `json:"gcloudProjectId" yaml:"gcloudProjectId"`
+	GCloudDisabled  bool              `json:"gcloudDisabled" yaml:"gcloudDisabled"`
+	SentryDSN       string            `json:"sentryDsn" yaml:"sentryDsn"`
+	LogLevel        string            `json:"logLevel" yaml:"logLevel"`
+	LogFormat       string            `json:"logFormat" yaml:"logFormat"`
+	LogLevelSignals bool              `json:"logLevelSignals" yaml:"logLevelSignals"`
+	Tracing         TracingFileConfig `json:"tracing" yaml:"tracing"`
+}
+
+// TracingFileConfig is the tracing section of FileConfig.
+type TracingFileConfig struct {
+	Exporter    string  `json:"exporter" yaml:"exporter"`
+	Endpoint    string  `json:"endpoint" yaml:"endpoint"`
+	Insecure    bool    `json:"insecure" yaml:"insecure"`
+	SampleRatio float64 `json:"sampleRatio" yaml:"sampleRatio"`
+}
+
+// ConfigFromEnv returns the configuration set in the OBS_* environment variables. The
+// service name, version and GCP project are detected on Cloud Run, GKE and GCE when
+// they are not set. The configuration is validated, see Config.Validate.
+func ConfigFromEnv() (Config, error) {
+	return configFrom(Config{}, os.LookupEnv)
+}
+
+// LoadConfig reads the configuration from a YAML or JSON file, depending on its
+// extension, see FileConfig. The environment variables read by ConfigFromEnv take
+// precedence over the file.
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("obs: cannot read config: %w", err)
+	}
+	var file FileConfig
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.DisallowUnknownFields()
+		err = d.Decode(&file)
+	} else {
+		d := yaml.NewDecoder(bytes.NewReader(data))
+		d.KnownFields(true)
+		err = d.Decode(&file)
+	}
+	if err != nil {
+		return Config{}, fmt.Errorf("obs: cannot parse config %s: %w", path, err)
+	}
+	return configFrom(file.config(), os.LookupEnv)
+}
+
+func (f FileConfig) config() Config {
+	return Config{
+		GCloudConfig: errtrack.GoogleCloudErrorReportingConfig{
+			ServiceName:     f.ServiceName,
+			ServiceVersion:  f.ServiceVersion,
+			GCloudProjectID: f.GCloudProjectID,
+		},
+		NOGCloudEnabled: f.GCloudDisabled,
+		SentryConfig:    errtrack.SentryConfig{SentryDSN: f.SentryDSN},
+		LogLevel:        f.LogLevel,
+		LogFormat:       f.LogFormat,
+		LogLevelSignals: f.LogLevelSignals,
+		TracingConfig: TracingConfig{
+			Exporter:    f.Tracing.Exporter,
+			Endpoint:    f.Tracing.Endpoint,
+			Insecure:    f.Tracing.Insecure,
+			SampleRatio: f.Tracing.SampleRatio,
+		},
+	}
+}
+
+// configFrom overrides config with the environment variables returned by lookup,
+// detects the service and validates the result.
+func configFrom(config Config, lookup func(string) (string, bool)) (Config, error) {
+	var errs []error
+	str := func(key string, dst *string) {
+		if v, ok := lookup(key); ok && v != "" {
+			*dst = v
+		}
+	}
+	boolean := func(key string, dst *bool) {
+		if v, ok := lookup(key); ok && v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("obs: invalid %s %q: not a boolean", key, v))
+				return
+			}
+			*dst = b
+		}
+	}
+
+	str(EnvSentryDSN, &config.SentryConfig.SentryDSN)
+	str(EnvServiceName, &config.GCloudConfig.ServiceName)
+	str(EnvServiceVersion, &config.GCloudConfig.ServiceVersion)
+	str(EnvLogLevel, &config.LogLevel)
+	str(EnvLogFormat, &config.LogFormat)
+	boolean(EnvGCloudDisabled, &config.NOGCloudEnabled)
+	str(EnvTracingExporter, &config.TracingConfig.Exporter)
+	str(EnvTracingEndpoint, &config.TracingConfig.Endpoint)
+	boolean(EnvTracingInsecure, &config.TracingConfig.Insecure)
+	if v, ok := lookup(EnvTracingSampleRatio); ok && v != "" {
+		ratio, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("obs: invalid %s %q: not a number", EnvTracingSampleRatio, v))
+		} else {
+			config.TracingConfig.SampleRatio = ratio
+		}
+	}
+
+	detectService(&config, lookup)
+	if config.SentryConfig.ServiceVersion == "" {
+		config.SentryConfig.ServiceVersion = config.GCloudConfig.ServiceVersion
+	}
+	errs = append(errs, config.Validate())
+	return config, errors.Join(errs...)
+}
+
+// onGCE reports whether the process runs on Google Cloud, it is replaced in tests.
+var onGCE = metadata.OnGCE
+
+// detectService fills the service name, version and GCP project that are not set,
+// using the environment of Cloud Run and GKE and the GCE metadata server.
+func detectService(config *Config, lookup func(string) (string, bool)) {
+	gcloud := &config.GCloudConfig
+	if gcloud.ServiceName == "" {
+		if service, ok := lookup("K_SERVICE"); ok {
+			gcloud.ServiceName = service
+		} else if _, ok := lookup("KUBERNETES_SERVICE_HOST"); ok {
+			host, _ := os.Hostname()
+			gcloud.ServiceName = podService(host)
+		}
+	}
+	if gcloud.ServiceVersion == "" {
+		if revision, ok := lookup("K_REVISION"); ok {
+			gcloud.ServiceVersion = revision
+		}
+	}
+	if gcloud.GCloudProjectID == "" {
+		if project, ok := lookup("GOOGLE_CLOUD_PROJECT"); ok {
+			gcloud.GCloudProjectID = project
+		} else if !config.NOGCloudEnabled && onGCE() {
+			gcloud.GCloudProjectID, _ = metadata.ProjectID()
+		}
+	}
+	if gcloud.ServiceName == "" && !config.NOGCloudEnabled && onGCE() {
+		if name, err := metadata.InstanceName(); err == nil {
+			gcloud.ServiceName = trimSuffixes(name, 1)
+		}
+	}
+}
+
+// podService returns the deployment name of a pod name like api-7d9f8b6c5-x2k4z.
+func podService(podName string) string {
+	return trimSuffixes(podName, 2)
+}
+
+// trimSuffixes removes the last n dash-separated segments of name, when it has more.
+func trimSuffixes(name string, n int) string {
+	parts := strings.Split(name, "-")
+	if len(parts) <= n {
+		return name
+	}
+	return strings.Join(parts[:len(parts)-n], "-")
+}
+
+// Validate returns the errors of the configuration, joined.
+func (c Config) Validate() error {
+	var errs []error
+	if c.LogLevel != "" {
+		if _, err := ParseLevel(c.LogLevel); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if _, err := logformat.Parse(c.LogFormat); err != nil {
+		errs = append(errs, err)
+	}
+	if c.SentryConfig.SentryDSN != "" {
+		if _, err := sentry.NewDsn(c.SentryConfig.SentryDSN); err != nil {
+			errs = append(errs, fmt.Errorf("obs: invalid Sentry DSN: %w", err))
+		}
+	}
+	if !c.NOGCloudEnabled {
+		if c.GCloudConfig.GCloudProjectID == "" {
+			errs = append(errs, errors.New("obs: GCP project id is required when GCP is enabled"))
+		}
+		if c.GCloudConfig.ServiceName == "" {
+			errs = append(errs, errors.New("obs: service name is required when GCP is enabled"))
+		}
+	}
+	switch c.TracingConfig.Exporter {
+	case "", TracingOTLP, TracingStdout:
+	default:
+		errs = append(errs, fmt.Errorf("obs: unknown tracing exporter %q", c.TracingConfig.Exporter))
+	}
+	if r := c.TracingConfig.SampleRatio; r < 0 || r > 1 {
+		errs = append(errs, fmt.Errorf("obs: tracing sample ratio %v is not between 0 and 1", r))
+	}
+	return errors.Join(errs...)
+}
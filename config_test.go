@@ -0,0 +1,79 @@
+package obs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	onGCE = func() bool { return false }
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("K_SERVICE", "api")
+	t.Setenv("K_REVISION", "api-00042-xyz")
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "my-project")
+	t.Setenv(EnvSentryDSN, "https://key@sentry.example.com/42")
+	t.Setenv(EnvLogLevel, "debug")
+	t.Setenv(EnvTracingSampleRatio, "0.5")
+
+	config, err := ConfigFromEnv()
+	assert.Nil(t, err)
+	assert.Equal(t, "api", config.GCloudConfig.ServiceName)
+	assert.Equal(t, "api-00042-xyz", config.GCloudConfig.ServiceVersion)
+	assert.Equal(t, "api-00042-xyz", config.SentryConfig.ServiceVersion)
+	assert.Equal(t, "my-project", config.GCloudConfig.GCloudProjectID)
+	assert.Equal(t, "https://key@sentry.example.com/42", config.SentryConfig.SentryDSN)
+	assert.Equal(t, "debug", config.LogLevel)
+	assert.Equal(t, 0.5, config.TracingConfig.SampleRatio)
+
+	t.Setenv(EnvServiceName, "billing")
+	config, err = ConfigFromEnv()
+	assert.Nil(t, err)
+	assert.Equal(t, "billing", config.GCloudConfig.ServiceName)
+}
+
+func TestConfigValidation(t *testing.T) {
+	t.Setenv(EnvLogLevel, "verbose")
+	t.Setenv(EnvSentryDSN, "not a dsn")
+	t.Setenv(EnvGCloudDisabled, "nope")
+	t.Setenv(EnvTracingExporter, "jaeger")
+
+	_, err := ConfigFromEnv()
+	assert.ErrorContains(t, err, `unknown log level "verbose"`)
+	assert.ErrorContains(t, err, "invalid Sentry DSN")
+	assert.ErrorContains(t, err, `invalid OBS_GCLOUD_DISABLED "nope"`)
+	assert.ErrorContains(t, err, "GCP project id is required")
+	assert.ErrorContains(t, err, `unknown tracing exporter "jaeger"`)
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "obs.yaml")
+	err := os.WriteFile(path, []byte("serviceName: api\nserviceVersion: 1.2.0\ngcloudDisabled: true\nlogFormat: logfmt\ntracing:\n  exporter: stdout\n"), 0o600)
+	assert.Nil(t, err)
+	t.Setenv(EnvServiceVersion, "1.3.0")
+
+	config, err := LoadConfig(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "api", config.GCloudConfig.ServiceName)
+	assert.Equal(t, "1.3.0", config.GCloudConfig.ServiceVersion)
+	assert.Equal(t, "1.3.0", config.SentryConfig.ServiceVersion)
+	assert.True(t, config.NOGCloudEnabled)
+	assert.Equal(t, "logfmt", config.LogFormat)
+	assert.Equal(t, TracingStdout, config.TracingConfig.Exporter)
+
+	path = filepath.Join(dir, "obs.json")
+	err = os.WriteFile(path, []byte(`{"serviceName":"api","gcloudDisabled":true,"sentry":"x"}`), 0o600)
+	assert.Nil(t, err)
+	_, err = LoadConfig(path)
+	assert.ErrorContains(t, err, `unknown field "sentry"`)
+}
+
+func TestPodService(t *testing.T) {
+	assert.Equal(t, "billing-api", podService("billing-api-7d9f8b6c5-x2k4z"))
+	assert.Equal(t, "api", podService("api"))
+}
@@ -0,0 +1,99 @@
+package obs
+
+import (
+	"context"
+
+	"github.com/JoinVerse/obs/hlog"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tags added to the captured errors from the context.
+const (
+	requestIDTag = "request_id"
+	userTag      = "user_id"
+	traceIDTag   = "trace_id"
+	spanIDTag    = "span_id"
+)
+
+type tagsKey struct{}
+
+type userKey struct{}
+
+// WithTags returns a copy of ctx carrying the given tags merged with the ones already
+// in ctx. Tags are sent along with the errors captured with the context-aware methods.
+func WithTags(ctx context.Context, tags map[string]string) context.Context {
+	merged := make(map[string]string, len(tags))
+	for k, v := range TagsFromContext(ctx) {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, tagsKey{}, merged)
+}
+
+// TagsFromContext returns the tags accumulated in ctx by WithTags.
+func TagsFromContext(ctx context.Context) map[string]string {
+	tags, _ := ctx.Value(tagsKey{}).(map[string]string)
+	return tags
+}
+
+// WithUser returns a copy of ctx carrying the id of the user doing the request.
+func WithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFromContext returns the user id set by WithUser.
+func UserFromContext(ctx context.Context) (string, bool) {
+	user, ok := ctx.Value(userKey{}).(string)
+	return user, ok
+}
+
+// contextTags returns the tags of ctx, its request id, user and trace ids, merged with the given tags.
+func contextTags(ctx context.Context, tags map[string]string) map[string]string {
+	merged := make(map[string]string, len(tags)+2)
+	for k, v := range TagsFromContext(ctx) {
+		merged[k] = v
+	}
+	if id, ok := hlog.RequestIDFromContext(ctx); ok {
+		merged[requestIDTag] = id
+	}
+	if user, ok := UserFromContext(ctx); ok {
+		merged[userTag] = user
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		merged[traceIDTag] = sc.TraceID().String()
+		merged[spanIDTag] = sc.SpanID().String()
+	}
+	for k, v := range tags {
+		merged[k] = v
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
+// FromContext returns the request-scoped logger installed in ctx by hlog, sharing the
+// level of l, or l when there is none. The user and the tags of ctx are added to it.
+func (l *Logger) FromContext(ctx context.Context) *Logger {
+	logger := l
+	if zl := zerolog.Ctx(ctx); zl.GetLevel() != zerolog.Disabled {
+		logger = &Logger{zl: *zl, level: l.level, projectID: l.projectID, sampler: l.sampler}
+	} else {
+		if id, ok := hlog.RequestIDFromContext(ctx); ok {
+			logger = logger.With(String("requestId", id))
+		}
+		if fields := hlog.TraceFields(ctx, l.projectID); fields != nil {
+			logger = &Logger{zl: logger.zl.With().Fields(fields).Logger(), level: l.level, projectID: l.projectID, sampler: l.sampler}
+		}
+	}
+	if user, ok := UserFromContext(ctx); ok {
+		logger = logger.With(String("userId", user))
+	}
+	if tags := TagsFromContext(ctx); len(tags) > 0 {
+		logger = logger.With(Any("tags", tags))
+	}
+	return logger
+}
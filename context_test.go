@@ -0,0 +1,106 @@
+package obs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/JoinVerse/obs/errtrack"
+	"github.com/JoinVerse/obs/errtrack/errtracktest"
+	"github.com/JoinVerse/obs/hlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestObserver(rec *errtracktest.Recorder) Observer {
+	errTrack := errtrack.New()
+	errTrack.Register(rec)
+	return Observer{log: NewNopLogger(), errTrack: errTrack, stop: func() {}}
+}
+
+func TestErrorCtx(t *testing.T) {
+	rec := &errtracktest.Recorder{}
+	observer := newTestObserver(rec)
+	out := &bytes.Buffer{}
+	logger := hlog.NewWithWriter(out)
+
+	h := logger.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := WithTags(WithUser(r.Context(), "42"), map[string]string{"tenant": "acme"})
+		observer.ErrorTagsCtx(ctx, "cannot save", map[string]string{"key": "value"}, errors.New("db: timeout"))
+		out.Reset()
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Request-Id", "randomRequest123")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	observer.Close()
+
+	if assert.Len(t, rec.Captures(), 1) {
+		assert.Equal(t, map[string]string{
+			"request_id": "randomRequest123",
+			"user_id":    "42",
+			"tenant":     "acme",
+			"key":        "value",
+		}, rec.Captures()[0].Tags)
+	}
+}
+
+func TestLoggerFromContext(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := hlog.NewWithWriter(out)
+	observer := Observer{log: NewNopLogger()}
+
+	h := logger.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		out.Reset()
+		observer.InfoCtx(WithUser(r.Context(), "42"), "hello")
+		line := decodeLine(t, out)
+		assert.Equal(t, "randomRequest123", line["requestId"])
+		assert.Equal(t, "42", line["userId"])
+		assert.Equal(t, "hello", line["message"])
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Request-Id", "randomRequest123")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+}
+
+func TestErrorClassified(t *testing.T) {
+	rec := &errtracktest.Recorder{}
+	errTrack := errtrack.NewWithConfig(errtrack.Config{Classifier: errtrack.NewClassifier(
+		errtrack.ExpectedErrors,
+		errtrack.Rule{Decision: errtrack.Warn, Message: regexp.MustCompile(`^validation: `)},
+	)})
+	errTrack.Register(rec)
+	out := &bytes.Buffer{}
+	observer := Observer{log: NewLoggerWithWriter(out), errTrack: errTrack, stop: func() {}}
+
+	observer.Error("cannot save", errors.New("validation: name is required"))
+	assert.Contains(t, out.String(), `"level":"warn"`)
+	out.Reset()
+	observer.Error("cannot save", context.Canceled)
+	assert.Contains(t, out.String(), `"level":"error"`)
+	observer.Close()
+
+	assert.Equal(t, []errtrack.Level{errtrack.LevelWarning}, rec.Levels(), "Canceled errors must not be sent")
+}
+
+func TestWarn(t *testing.T) {
+	rec := &errtracktest.Recorder{}
+	errTrack := errtrack.New()
+	errTrack.Register(rec)
+	out := &bytes.Buffer{}
+	observer := Observer{log: NewLoggerWithWriter(out), errTrack: errTrack, stop: func() {}}
+
+	observer.WarnErr("cache miss", errors.New("cache: unavailable"))
+	assert.Contains(t, out.String(), `"level":"warn"`)
+	out.Reset()
+	observer.CaptureLevel(FatalLevel, "corrupted", map[string]string{"key": "value"}, nil, errors.New("db: checksum mismatch"))
+	assert.Contains(t, out.String(), `"level":"fatal"`)
+	out.Reset()
+	observer.HTTPErrorLevel(httptest.NewRequest(http.MethodGet, "/", nil), WarnLevel, nil, nil, errors.New("api: slow response"))
+	assert.Contains(t, out.String(), `"level":"warn"`)
+	observer.Close()
+
+	assert.Equal(t, []errtrack.Level{errtrack.LevelWarning, errtrack.LevelFatal, errtrack.LevelWarning}, rec.Levels())
+}
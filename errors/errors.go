@@ -0,0 +1,257 @@
+// Package errors creates errors carrying the stack trace where they were created, and
+// tags, context and a fingerprint accumulated while they go up the stack. The
+// ErrorTracker merges them into the reports, so the information known deep in the
+// stack is not lost by the time the error is captured.
+//
+// It can be used as a replacement of the standard errors package.
+package errors
+
+import (
+	"bytes"
+	stderrors "errors"
+	"fmt"
+	"runtime"
+)
+
+// Is, As, Unwrap and Join are the functions of the standard errors package.
+var (
+	Is     = stderrors.Is
+	As     = stderrors.As
+	Unwrap = stderrors.Unwrap
+	Join   = stderrors.Join
+)
+
+// Error is an error carrying a stack trace, tags, context and a fingerprint.
+// Any of them can be empty.
+type Error struct {
+	msg         string
+	cause       error
+	pcs         []uintptr
+	tags        map[string]string
+	context     map[string]interface{}
+	fingerprint []string
+}
+
+// New returns an error with the given message and the stack trace of the caller.
+func New(msg string) error {
+	return &Error{msg: msg, pcs: callers()}
+}
+
+// Errorf formats an error like fmt.Errorf, supporting %w, and records the stack trace
+// of the caller unless a wrapped error already carries one.
+func Errorf(format string, args ...interface{}) error {
+	err := fmt.Errorf(format, args...)
+	e := &Error{cause: err}
+	if !hasStack(err) {
+		e.pcs = callers()
+	}
+	return e
+}
+
+// Wrap returns an error prefixing the message of err with msg, recording the stack
+// trace of the caller unless err already carries one. It returns nil if err is nil.
+func Wrap(err error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	e := &Error{msg: msg, cause: err}
+	if !hasStack(err) {
+		e.pcs = callers()
+	}
+	return e
+}
+
+// WithTags returns err with the given tags, sent as tags to the error trackers. Tags
+// of the outer errors take precedence. It returns nil if err is nil.
+func WithTags(err error, tags map[string]string) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{cause: err, tags: tags}
+}
+
+// WithContext returns err with the given context, sent as context to the error
+// trackers. Keys of the outer errors take precedence. It returns nil if err is nil.
+func WithContext(err error, context map[string]interface{}) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{cause: err, context: context}
+}
+
+// WithFingerprint returns err with a fingerprint used to group it in the error trackers
+// and by the deduplication, instead of its type, message and caller. The outermost
+// fingerprint is used. It returns nil if err is nil.
+func WithFingerprint(err error, fingerprint ...string) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{cause: err, fingerprint: fingerprint}
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.cause == nil:
+		return e.msg
+	case e.msg == "":
+		return e.cause.Error()
+	}
+	return e.msg + ": " + e.cause.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// StackTrace returns the program counters of the stack trace recorded by this error
+// or, if it has none, the first one of the errors it wraps, including the errors
+// joined by errors.Join or several %w verbs. It is the format used by Sentry to
+// extract the stack frames.
+func (e *Error) StackTrace() []uintptr {
+	return stackTrace(e)
+}
+
+// Stack returns the stack trace formatted like runtime/debug.Stack, the format used
+// by GCP Error Reporting. The stack of a wrapped error, like errtrack.PanicError, is
+// returned as is. It is empty when there is no stack trace.
+func (e *Error) Stack() []byte {
+	return stack(e)
+}
+
+// stackTrace returns the first stack trace of the chain of err, walked depth-first
+// like errors.As does.
+func stackTrace(err error) []uintptr {
+	for err != nil {
+		if x, ok := err.(*Error); ok {
+			if x.pcs != nil {
+				return x.pcs
+			}
+		} else if s, ok := err.(interface{ StackTrace() []uintptr }); ok && len(s.StackTrace()) > 0 {
+			return s.StackTrace()
+		}
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, err := range joined.Unwrap() {
+				if pcs := stackTrace(err); pcs != nil {
+					return pcs
+				}
+			}
+			return nil
+		}
+		err = stderrors.Unwrap(err)
+	}
+	return nil
+}
+
+// stack returns the first stack of the chain of err, walked like stackTrace.
+func stack(err error) []byte {
+	for err != nil {
+		if x, ok := err.(*Error); ok {
+			if x.pcs != nil {
+				return FormatStack(x.pcs)
+			}
+		} else if s, ok := err.(interface{ Stack() []byte }); ok && len(s.Stack()) > 0 {
+			return s.Stack()
+		} else if s, ok := err.(interface{ StackTrace() []uintptr }); ok && len(s.StackTrace()) > 0 {
+			return FormatStack(s.StackTrace())
+		}
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, err := range joined.Unwrap() {
+				if b := stack(err); b != nil {
+					return b
+				}
+			}
+			return nil
+		}
+		err = stderrors.Unwrap(err)
+	}
+	return nil
+}
+
+// FormatStack formats the program counters like runtime/debug.Stack, the format used by
+// GCP Error Reporting.
+func FormatStack(pcs []uintptr) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString("goroutine 1 [running]:\n")
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(buf, "%s(...)\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			return buf.Bytes()
+		}
+	}
+}
+
+// Tags returns the tags of the errors of the chain of err.
+func Tags(err error) map[string]string {
+	var tags map[string]string
+	walk(err, func(e *Error) {
+		for k, v := range e.tags {
+			if tags == nil {
+				tags = map[string]string{}
+			}
+			if _, ok := tags[k]; !ok {
+				tags[k] = v
+			}
+		}
+	})
+	return tags
+}
+
+// Context returns the context of the errors of the chain of err.
+func Context(err error) map[string]interface{} {
+	var context map[string]interface{}
+	walk(err, func(e *Error) {
+		for k, v := range e.context {
+			if context == nil {
+				context = map[string]interface{}{}
+			}
+			if _, ok := context[k]; !ok {
+				context[k] = v
+			}
+		}
+	})
+	return context
+}
+
+// Fingerprint returns the outermost fingerprint of the chain of err.
+func Fingerprint(err error) []string {
+	var fingerprint []string
+	walk(err, func(e *Error) {
+		if fingerprint == nil && len(e.fingerprint) > 0 {
+			fingerprint = e.fingerprint
+		}
+	})
+	return fingerprint
+}
+
+// walk calls f with the errors of the chain of err created by this package, from the
+// outermost. Errors joined with Join are walked too.
+func walk(err error, f func(e *Error)) {
+	for err != nil {
+		if e, ok := err.(*Error); ok {
+			f(e)
+		}
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, err := range joined.Unwrap() {
+				walk(err, f)
+			}
+			return
+		}
+		err = stderrors.Unwrap(err)
+	}
+}
+
+// hasStack reports whether an error of the chain of err carries a stack trace.
+func hasStack(err error) bool {
+	var s interface{ StackTrace() []uintptr }
+	return stderrors.As(err, &s) && len(s.StackTrace()) > 0
+}
+
+// callers returns the stack trace of the caller of the function calling it.
+func callers() []uintptr {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	return pcs[:n]
+}
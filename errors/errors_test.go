@@ -0,0 +1,78 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrap(t *testing.T) {
+	err := Wrap(io.EOF, "read config")
+	assert.EqualError(t, err, "read config: EOF")
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Nil(t, Wrap(nil, "read config"))
+
+	errf := Errorf("load %s: %w", "users", err)
+	assert.EqualError(t, errf, "load users: read config: EOF")
+	assert.ErrorIs(t, errf, io.EOF)
+}
+
+func TestStackTrace(t *testing.T) {
+	err := New("boom")
+	pcs := err.(*Error).StackTrace()
+	if assert.NotEmpty(t, pcs) {
+		assert.Contains(t, string(err.(*Error).Stack()), "errors.TestStackTrace(...)")
+	}
+
+	wrapped := Wrap(WithTags(err, map[string]string{"k": "v"}), "handler")
+	assert.Equal(t, pcs, wrapped.(*Error).StackTrace(), "The innermost stack must be kept")
+	assert.True(t, strings.HasPrefix(string(wrapped.(*Error).Stack()), "goroutine 1 [running]:\n"))
+}
+
+func TestTagsContextFingerprint(t *testing.T) {
+	err := WithTags(New("boom"), map[string]string{"order_id": "42", "step": "inner"})
+	err = WithContext(err, map[string]interface{}{"attempt": 3})
+	err = WithFingerprint(err, "payments", "boom")
+	err = fmt.Errorf("handler: %w", WithTags(err, map[string]string{"step": "outer"}))
+	err = WithFingerprint(err, "outer")
+
+	assert.Equal(t, map[string]string{"order_id": "42", "step": "outer"}, Tags(err))
+	assert.Equal(t, map[string]interface{}{"attempt": 3}, Context(err))
+	assert.Equal(t, []string{"outer"}, Fingerprint(err))
+
+	joined := Join(io.EOF, WithTags(io.ErrUnexpectedEOF, map[string]string{"k": "v"}))
+	assert.Equal(t, map[string]string{"k": "v"}, Tags(joined))
+	assert.Nil(t, Tags(io.EOF))
+}
+
+// stackError carries a stack trace like errtrack.PanicError.
+type stackError struct {
+	pcs []uintptr
+}
+
+func (e *stackError) Error() string         { return "panic: boom" }
+func (e *stackError) StackTrace() []uintptr { return e.pcs }
+func (e *stackError) Stack() []byte         { return []byte("goroutine 7 [running]:\n") }
+
+func TestStackTraceOfOtherErrors(t *testing.T) {
+	inner := &stackError{pcs: []uintptr{1, 2, 3}}
+	err := Wrap(WithTags(inner, map[string]string{"k": "v"}), "ctx")
+
+	assert.Equal(t, inner.pcs, err.(*Error).StackTrace())
+	assert.Equal(t, inner.Stack(), err.(*Error).Stack())
+	assert.Nil(t, WithTags(io.EOF, nil).(*Error).StackTrace())
+}
+
+func TestStackTraceOfJoinedErrors(t *testing.T) {
+	err := New("boom")
+	pcs := err.(*Error).StackTrace()
+
+	errf := Errorf("retry: %w, %w", io.EOF, err)
+	assert.Equal(t, pcs, errf.(*Error).StackTrace())
+	assert.Equal(t, err.(*Error).Stack(), errf.(*Error).Stack())
+
+	assert.NotEmpty(t, Errorf("retry: %w, %w", io.EOF, io.ErrUnexpectedEOF).(*Error).StackTrace(), "Errorf records a stack when no wrapped error has one")
+}
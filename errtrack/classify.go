@@ -0,0 +1,148 @@
+package errtrack
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"regexp"
+	"syscall"
+)
+
+// Decision is what happens to a captured error.
+type Decision int
+
+const (
+	// Report sends the error to the exporters.
+	Report Decision = iota
+	// LogOnly discards the error, it is only logged by the Observer.
+	LogOnly
+	// Warn sends the error at the warning level, it is logged at the warning level by
+	// the Observer.
+	Warn
+)
+
+// Rule matches the errors given the Decision. An error matches a rule when it matches
+// any of its criteria.
+type Rule struct {
+	Decision Decision
+	// Is matches the errors wrapping any of the targets, see errors.Is.
+	Is []error
+	// Types matches the errors whose chain holds an error of the same type as any of
+	// them, e.g. (*json.SyntaxError)(nil).
+	Types []error
+	// Message matches the errors whose message matches the expression.
+	Message *regexp.Regexp
+	// Statuses matches the errors of the requests answered with any of the statuses,
+	// given by an error of the chain with a StatusCode() int method, or by the status
+	// of the response already written when the error is captured with CaptureHTTPError,
+	// see WithResponseStatus.
+	Statuses []int
+	// Match matches the errors it returns true for. The request is nil when the error
+	// is not captured with CaptureHTTPError.
+	Match func(err error, r *http.Request) bool
+}
+
+// ExpectedErrors logs only the errors of canceled contexts and disconnected clients.
+var ExpectedErrors = Rule{
+	Decision: LogOnly,
+	Is:       []error{context.Canceled, syscall.EPIPE, syscall.ECONNRESET},
+}
+
+// Classifier decides what happens to the captured errors with the first matching rule.
+// Errors matching none of the rules are reported. A nil Classifier reports every error.
+type Classifier struct {
+	rules []Rule
+}
+
+// NewClassifier creates a Classifier with the given rules.
+func NewClassifier(rules ...Rule) *Classifier {
+	return &Classifier{rules: rules}
+}
+
+// Classify returns the decision for err, captured along with the request r, which can be nil.
+func (c *Classifier) Classify(err error, r *http.Request) Decision {
+	if c == nil || err == nil {
+		return Report
+	}
+	for _, rule := range c.rules {
+		if rule.matches(err, r) {
+			return rule.Decision
+		}
+	}
+	return Report
+}
+
+func (rule Rule) matches(err error, r *http.Request) bool {
+	for _, target := range rule.Is {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	if len(rule.Types) > 0 && hasType(err, rule.Types) {
+		return true
+	}
+	if rule.Message != nil && rule.Message.MatchString(err.Error()) {
+		return true
+	}
+	if len(rule.Statuses) > 0 {
+		var s interface{ StatusCode() int }
+		if errors.As(err, &s) && hasStatus(rule.Statuses, s.StatusCode()) {
+			return true
+		}
+		if hasStatus(rule.Statuses, ResponseStatus(r)) {
+			return true
+		}
+	}
+	return rule.Match != nil && rule.Match(err, r)
+}
+
+func hasStatus(statuses []int, status int) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+type responseStatusKey struct{}
+
+// WithResponseStatus returns a copy of ctx holding status, returning the status of the
+// response to the request, or 0 while it is not written. It is set by the hlog middlewares.
+func WithResponseStatus(ctx context.Context, status func() int) context.Context {
+	return context.WithValue(ctx, responseStatusKey{}, status)
+}
+
+// ResponseStatus returns the status of the response to r written so far, or 0 when it is
+// not written or not known.
+func ResponseStatus(r *http.Request) int {
+	if r == nil {
+		return 0
+	}
+	if status, ok := r.Context().Value(responseStatusKey{}).(func() int); ok {
+		return status()
+	}
+	return 0
+}
+
+// hasType reports whether the chain of err holds an error of the type of any of types.
+func hasType(err error, types []error) bool {
+	for err != nil {
+		for _, t := range types {
+			if reflect.TypeOf(err) == reflect.TypeOf(t) {
+				return true
+			}
+		}
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, err := range joined.Unwrap() {
+				if hasType(err, types) {
+					return true
+				}
+			}
+			return false
+		}
+		err = errors.Unwrap(err)
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package errtrack_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/JoinVerse/obs/errtrack"
+	"github.com/JoinVerse/obs/errtrack/errtracktest"
+	"github.com/stretchr/testify/assert"
+)
+
+type statusError int
+
+func (e statusError) Error() string   { return http.StatusText(int(e)) }
+func (e statusError) StatusCode() int { return int(e) }
+
+func TestClassify(t *testing.T) {
+	c := errtrack.NewClassifier(
+		errtrack.ExpectedErrors,
+		errtrack.Rule{Decision: errtrack.Warn, Types: []error{(*json.SyntaxError)(nil)}, Statuses: []int{http.StatusNotFound}},
+		errtrack.Rule{Decision: errtrack.LogOnly, Message: regexp.MustCompile(`^validation: `)},
+		errtrack.Rule{Decision: errtrack.Warn, Match: func(err error, r *http.Request) bool { return r != nil && r.URL.Path == "/legacy" }},
+	)
+
+	assert.Equal(t, errtrack.LogOnly, c.Classify(fmt.Errorf("handler: %w", context.Canceled), nil))
+	assert.Equal(t, errtrack.Warn, c.Classify(fmt.Errorf("decode: %w", json.Unmarshal([]byte("{"), &struct{}{})), nil))
+	assert.Equal(t, errtrack.Warn, c.Classify(fmt.Errorf("api: %w", statusError(http.StatusNotFound)), nil))
+	assert.Equal(t, errtrack.Report, c.Classify(statusError(http.StatusBadGateway), nil))
+	assert.Equal(t, errtrack.LogOnly, c.Classify(errors.New("validation: name is required"), nil))
+	legacy, _ := http.NewRequest(http.MethodGet, "/legacy", nil)
+	assert.Equal(t, errtrack.Warn, c.Classify(errors.New("boom"), legacy))
+	assert.Equal(t, errtrack.Report, c.Classify(errors.New("boom"), nil))
+	assert.Equal(t, errtrack.Report, (*errtrack.Classifier)(nil).Classify(context.Canceled, nil))
+}
+
+func TestCaptureErrorClassified(t *testing.T) {
+	rec := &errtracktest.Recorder{}
+	tracker := errtrack.NewWithConfig(errtrack.Config{Classifier: errtrack.NewClassifier(errtrack.ExpectedErrors)})
+	tracker.Register(rec)
+
+	tracker.CaptureError(context.Canceled, nil, nil)
+	tracker.CaptureHTTPError(fmt.Errorf("write: %w", context.Canceled), nil, nil, nil)
+	tracker.CaptureError(errors.New("errtrack: boom"), nil, nil)
+	tracker.Close()
+
+	if assert.Len(t, rec.Errors(), 1) {
+		assert.EqualError(t, rec.Errors()[0], "errtrack: boom")
+	}
+}
+
+func TestCaptureHTTPErrorResponseStatus(t *testing.T) {
+	rec := &errtracktest.Recorder{}
+	tracker := errtrack.NewWithConfig(errtrack.Config{Classifier: errtrack.NewClassifier(
+		errtrack.Rule{Decision: errtrack.LogOnly, Statuses: []int{http.StatusNotFound}},
+	)})
+	tracker.Register(rec)
+
+	status := 0
+	r, _ := http.NewRequest(http.MethodGet, "/users/42", nil)
+	r = r.WithContext(errtrack.WithResponseStatus(r.Context(), func() int { return status }))
+
+	tracker.CaptureHTTPError(errors.New("user not found"), r, nil, nil)
+	status = http.StatusNotFound
+	tracker.CaptureHTTPError(errors.New("user not found"), r, nil, nil)
+	tracker.CaptureError(errors.New("user not found"), nil, nil)
+	tracker.Close()
+
+	assert.Len(t, rec.Errors(), 2)
+	assert.Equal(t, http.StatusNotFound, errtrack.ResponseStatus(r))
+	assert.Equal(t, 0, errtrack.ResponseStatus(nil))
+}
+
+func TestCaptureErrorLevel(t *testing.T) {
+	rec := &errtracktest.Recorder{}
+	tracker := errtrack.NewWithConfig(errtrack.Config{Classifier: errtrack.NewClassifier(errtrack.Rule{Decision: errtrack.Warn, Is: []error{io.ErrUnexpectedEOF}})})
+	tracker.Register(rec)
+
+	tracker.CaptureError(errors.New("errtrack: boom"), nil, nil)
+	tracker.CaptureErrorLevel(errtrack.LevelInfo, errors.New("errtrack: retried"), nil, nil)
+	tracker.CaptureErrorLevel(errtrack.LevelFatal, io.ErrUnexpectedEOF, nil, nil)
+	tracker.Close()
+
+	assert.Equal(t, []errtrack.Level{errtrack.LevelError, errtrack.LevelInfo, errtrack.LevelWarning}, rec.Levels())
+}
@@ -0,0 +1,238 @@
+package errtrack
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	obserrors "github.com/JoinVerse/obs/errors"
+)
+
+// DedupConfig handles errors deduplication and rate limiting configuration.
+// Errors are identified by a fingerprint built from the types of the error chain,
+// the error message with its variable parts removed and the frame that captured it.
+type DedupConfig struct {
+	// Window is the period duplicated errors are aggregated over. At the end of each
+	// window a summary with the number of suppressed duplicates is sent.
+	// Zero disables the deduplication, the summaries of the errors suppressed by the
+	// rate limits are then sent every minute.
+	Window time.Duration
+	// MaxPerWindow is the number of occurrences of the same error sent per window.
+	// Defaults to 1.
+	MaxPerWindow int
+	// FingerprintRate is the number of occurrences of the same error per second allowed
+	// to be sent, on top of MaxPerWindow. Zero disables the limit.
+	FingerprintRate float64
+	// FingerprintBurst is the maximum burst of FingerprintRate. Defaults to 1.
+	FingerprintBurst int
+	// GlobalRate is the number of errors per second allowed to be sent. Zero disables the limit.
+	GlobalRate float64
+	// GlobalBurst is the maximum burst of GlobalRate. Defaults to 1.
+	GlobalBurst int
+}
+
+func (c DedupConfig) enabled() bool {
+	return c.Window > 0 || c.FingerprintRate > 0 || c.GlobalRate > 0
+}
+
+// defaultFlushPeriod is the period of the summaries when there is no Window.
+const defaultFlushPeriod = time.Minute
+
+// period returns how often the summaries are sent and the idle fingerprints evicted.
+func (c DedupConfig) period() time.Duration {
+	if c.Window > 0 {
+		return c.Window
+	}
+	return defaultFlushPeriod
+}
+
+// dedupEntry aggregates the occurrences of a fingerprint within the current window.
+type dedupEntry struct {
+	sample     capture
+	forwarded  int
+	suppressed int
+	bucket     *tokenBucket
+	seen       time.Time
+}
+
+// deduper decides which captures are sent and builds the summaries of the suppressed ones.
+type deduper struct {
+	config DedupConfig
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*dedupEntry
+	global  *tokenBucket
+}
+
+func newDeduper(config DedupConfig) *deduper {
+	if config.MaxPerWindow <= 0 {
+		config.MaxPerWindow = 1
+	}
+	d := &deduper{config: config, now: time.Now, entries: make(map[string]*dedupEntry)}
+	if config.GlobalRate > 0 {
+		d.global = newTokenBucket(config.GlobalRate, config.GlobalBurst)
+	}
+	return d
+}
+
+// allow reports whether the capture identified by fingerprint must be sent.
+func (d *deduper) allow(fingerprint string, c capture) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	entry, ok := d.entries[fingerprint]
+	if !ok {
+		entry = &dedupEntry{sample: c}
+		if d.config.FingerprintRate > 0 {
+			entry.bucket = newTokenBucket(d.config.FingerprintRate, d.config.FingerprintBurst)
+		}
+		d.entries[fingerprint] = entry
+	}
+	entry.seen = now
+
+	if d.config.Window > 0 && entry.forwarded >= d.config.MaxPerWindow {
+		entry.suppressed++
+		return false
+	}
+	if entry.bucket != nil && !entry.bucket.allow(now) {
+		entry.suppressed++
+		return false
+	}
+	if d.global != nil && !d.global.allow(now) {
+		entry.suppressed++
+		return false
+	}
+	entry.forwarded++
+	return true
+}
+
+// flush returns the summaries of the suppressed captures and starts a new window.
+func (d *deduper) flush() []capture {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	var summaries []capture
+	for fingerprint, entry := range d.entries {
+		if entry.suppressed > 0 {
+			summaries = append(summaries, summary(fingerprint, entry, d.config.period()))
+		} else if now.Sub(entry.seen) > d.config.period() {
+			delete(d.entries, fingerprint)
+			continue
+		}
+		entry.forwarded = 0
+		entry.suppressed = 0
+	}
+	return summaries
+}
+
+func summary(fingerprint string, entry *dedupEntry, window time.Duration) capture {
+	tags := copyTags(entry.sample.tags)
+	if tags == nil {
+		tags = make(map[string]string, 2)
+	}
+	tags["fingerprint"] = fingerprint
+	tags["duplicates"] = strconv.Itoa(entry.suppressed)
+	err := fmt.Errorf("errtrack: %d duplicates suppressed in the last %s: %w", entry.suppressed, window, entry.sample.err)
+	// Report the stack of the duplicates rather than the one of the worker.
+	return capture{err: err, level: entry.sample.level, stack: entry.sample.stack, tags: tags, context: entry.sample.context}
+}
+
+// tokenBucket is a rate limiter allowing bursts of up to burst events.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst <= 0 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+func (b *tokenBucket) allow(now time.Time) bool {
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+var variableParts = []struct {
+	re          *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`), "<uuid>"},
+	{regexp.MustCompile(`\b0x[0-9a-fA-F]+\b|\b[0-9a-fA-F]{8,}\b`), "<hex>"},
+	{regexp.MustCompile(`\d+`), "<n>"},
+}
+
+// messageTemplate removes the variable parts, like ids and numbers, of an error message.
+func messageTemplate(msg string) string {
+	for _, p := range variableParts {
+		msg = p.re.ReplaceAllString(msg, p.replacement)
+	}
+	return msg
+}
+
+// fingerprint identifies errors by the types of its chain, its message template and the caller frame,
+// or by the fingerprint set with errors.WithFingerprint.
+func fingerprint(err error, frame string) string {
+	h := sha1.New()
+	if parts := obserrors.Fingerprint(err); len(parts) > 0 {
+		h.Write([]byte(strings.Join(parts, "|")))
+		return hex.EncodeToString(h.Sum(nil))[:16]
+	}
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		fmt.Fprintf(h, "%T|", e)
+	}
+	if err != nil {
+		h.Write([]byte(messageTemplate(err.Error())))
+	}
+	h.Write([]byte("|" + frame))
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// modulePath is the path of this module, its frames are skipped when looking for the caller.
+var modulePath = strings.TrimSuffix(reflect.TypeOf(ErrorTracker{}).PkgPath(), "/errtrack")
+
+// callerFrame returns the first frame outside of this module that captured the error.
+func callerFrame() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var last string
+	for {
+		frame, more := frames.Next()
+		last = fmt.Sprintf("%s:%d", frame.Function, frame.Line)
+		if !isModuleFrame(frame.Function) || !more {
+			return last
+		}
+	}
+}
+
+func isModuleFrame(function string) bool {
+	if !strings.HasPrefix(function, modulePath) {
+		return false
+	}
+	rest := function[len(modulePath):]
+	return !strings.HasPrefix(rest, "/examples/") && (strings.HasPrefix(rest, ".") || strings.HasPrefix(rest, "/"))
+}
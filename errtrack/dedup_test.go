@@ -0,0 +1,86 @@
+package errtrack
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	obserrors "github.com/JoinVerse/obs/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageTemplate(t *testing.T) {
+	assert.Equal(t,
+		"db: user <uuid> not found after <n> retries, request <hex>",
+		messageTemplate("db: user 4f1c2a3e-9b7d-4c1e-8f2a-1b2c3d4e5f60 not found after 3 retries, request 5f0e1d2c3b4a5968"),
+	)
+}
+
+func TestFingerprint(t *testing.T) {
+	errA := fmt.Errorf("handler: %w", errors.New("db: timeout after 30s"))
+	errB := fmt.Errorf("handler: %w", errors.New("db: timeout after 45s"))
+	errC := errors.New("handler: db: timeout after 45s")
+
+	assert.Equal(t, fingerprint(errA, "main.handler:10"), fingerprint(errB, "main.handler:10"))
+	assert.NotEqual(t, fingerprint(errA, "main.handler:10"), fingerprint(errA, "main.other:20"))
+	assert.NotEqual(t, fingerprint(errB, "main.handler:10"), fingerprint(errC, "main.handler:10"))
+
+	hinted := obserrors.WithFingerprint(errA, "db", "timeout")
+	assert.Equal(t, fingerprint(hinted, "main.handler:10"), fingerprint(obserrors.WithFingerprint(errC, "db", "timeout"), "main.other:20"))
+}
+
+func TestDeduperWindow(t *testing.T) {
+	now := time.Now()
+	d := newDeduper(DedupConfig{Window: time.Minute, MaxPerWindow: 2})
+	d.now = func() time.Time { return now }
+
+	c := newCapture(LevelError, errors.New("errtrack: boom"), map[string]string{"key": "value"}, nil, nil)
+	var sent int
+	for i := 0; i < 10; i++ {
+		if d.allow("fp", c) {
+			sent++
+		}
+	}
+	assert.Equal(t, 2, sent)
+
+	summaries := d.flush()
+	if assert.Len(t, summaries, 1) {
+		assert.Equal(t, "8", summaries[0].tags["duplicates"])
+		assert.Equal(t, "value", summaries[0].tags["key"])
+		assert.ErrorIs(t, summaries[0].err, c.err)
+	}
+	assert.True(t, d.allow("fp", c), "A new window must send the error again")
+}
+
+func TestDeduperRateLimits(t *testing.T) {
+	now := time.Now()
+	d := newDeduper(DedupConfig{FingerprintRate: 1, GlobalRate: 2, GlobalBurst: 2})
+	d.now = func() time.Time { return now }
+
+	c := newCapture(LevelError, errors.New("errtrack: boom"), nil, nil, nil)
+	assert.True(t, d.allow("a", c))
+	assert.False(t, d.allow("a", c), "Fingerprint bucket must be empty")
+	assert.True(t, d.allow("b", c))
+	assert.False(t, d.allow("c", c), "Global bucket must be empty")
+
+	now = now.Add(time.Second)
+	assert.True(t, d.allow("a", c))
+}
+
+func TestDeduperRateLimitsEvict(t *testing.T) {
+	now := time.Now()
+	d := newDeduper(DedupConfig{FingerprintRate: 1})
+	d.now = func() time.Time { return now }
+
+	c := newCapture(LevelError, errors.New("errtrack: boom"), nil, nil, nil)
+	assert.True(t, d.allow("a", c))
+	assert.False(t, d.allow("a", c))
+	if summaries := d.flush(); assert.Len(t, summaries, 1) {
+		assert.Equal(t, "1", summaries[0].tags["duplicates"])
+	}
+
+	now = now.Add(2 * defaultFlushPeriod)
+	assert.Empty(t, d.flush())
+	assert.Empty(t, d.entries, "Idle fingerprints must be evicted")
+}
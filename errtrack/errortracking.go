@@ -4,9 +4,13 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sync"
+	"time"
 
+	"github.com/JoinVerse/obs/clientip"
 	"github.com/JoinVerse/obs/errtrack/gcp"
 	"github.com/JoinVerse/obs/errtrack/sentry"
+	"github.com/JoinVerse/obs/redact"
 )
 
 // SentryConfig handles Sentry exporter configuration.
@@ -24,21 +28,107 @@ type GoogleCloudErrorReportingConfig struct {
 	OnGetUser       func(r *http.Request) string
 }
 
-// ErrorTracker ...
+// DropPolicy defines what happens when an exporter queue is full.
+type DropPolicy int
+
+const (
+	// DropNewest discards the error being captured.
+	DropNewest DropPolicy = iota
+	// DropOldest discards the oldest error waiting in the queue.
+	DropOldest
+	// Block waits until there is room in the queue.
+	Block
+)
+
+// Config handles ErrorTracker configuration.
+type Config struct {
+	// QueueSize is the number of errors each exporter can hold before DropPolicy is applied.
+	// Defaults to 100.
+	QueueSize int
+	// Workers is the number of goroutines sending the queued errors of each exporter.
+	// Defaults to 1.
+	Workers int
+	// DropPolicy defines what to do when an exporter queue is full. Defaults to DropNewest.
+	DropPolicy DropPolicy
+	// CloseTimeout is the maximum time Close waits for queued errors to be sent.
+	// Defaults to 5 seconds.
+	CloseTimeout time.Duration
+	// Dedup configures the aggregation of duplicated errors and the rate limits.
+	Dedup DedupConfig
+	// Redactor, when set, masks the sensitive data of the request url, headers and
+	// body, and of the context, before they are sent to any exporter, and of the tags
+	// appended to the GCP Error Reporting messages.
+	Redactor *redact.Redactor
+	// ClientIP resolves the IP address of the users of the captured requests. Defaults
+	// to a resolver trusting the private networks.
+	ClientIP *clientip.Resolver
+	// Classifier, when set, decides which errors are sent to the exporters, e.g. to skip
+	// the expected errors. Every error is sent by default.
+	Classifier *Classifier
+}
+
+func (c Config) withDefaults() Config {
+	if c.QueueSize <= 0 {
+		c.QueueSize = 100
+	}
+	if c.Workers <= 0 {
+		c.Workers = 1
+	}
+	if c.CloseTimeout <= 0 {
+		c.CloseTimeout = 5 * time.Second
+	}
+	return c
+}
+
+// ErrorTracker sends errors to the registered exporters. Errors are queued
+// and sent by background workers, so capturing never waits on network I/O
+// unless the Block policy is configured.
 type ErrorTracker struct {
-	errorExporters []errorExporter
+	config Config
+	dedup  *deduper
+	done   chan struct{}
+
+	mu        sync.RWMutex
+	queues    []*exporterQueue
+	closed    bool
+	closeOnce sync.Once
 }
 
-// errorExporter defines the interface to export errors to providers
-type errorExporter interface {
+// Exporter defines the interface to export errors to providers.
+// Implement it to plug your own backend into an ErrorTracker with Register.
+// Exporters receive the captured errors as is, except the Sentry and GCP exporters:
+// the errors without a stack trace are sent to them carrying the one of the capture site.
+type Exporter interface {
 	CaptureError(err error, tags map[string]string, context map[string]interface{})
 	CaptureHTTPError(err error, r *http.Request, tags map[string]string, context map[string]interface{})
 	Close()
 }
 
-// New creates a new ErrorTracker
+// New creates a new ErrorTracker with the default configuration.
 func New() *ErrorTracker {
-	return &ErrorTracker{}
+	return NewWithConfig(Config{})
+}
+
+// NewWithConfig creates a new ErrorTracker with the given configuration.
+func NewWithConfig(config Config) *ErrorTracker {
+	e := &ErrorTracker{config: config.withDefaults(), done: make(chan struct{})}
+	if config.Dedup.enabled() {
+		e.dedup = newDeduper(config.Dedup)
+		go e.summarize(config.Dedup.period())
+	}
+	return e
+}
+
+// Register adds the exporter to the list of exporters errors are sent to.
+// The exporter is closed when the ErrorTracker is closed.
+func (e *ErrorTracker) Register(exporter Exporter) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		exporter.Close()
+		return
+	}
+	e.queues = append(e.queues, newExporterQueue(exporter, e.config))
 }
 
 // InitSentry initializes Sentry error tracker
@@ -47,7 +137,8 @@ func (e *ErrorTracker) InitSentry(config SentryConfig) error {
 	if err != nil {
 		return fmt.Errorf("errtrack: cannot start Sentry error tracker %w", err)
 	}
-	e.errorExporters = append(e.errorExporters, sentryExporter)
+	sentryExporter.ClientIP = e.config.ClientIP
+	e.Register(sentryExporter)
 
 	return nil
 }
@@ -63,27 +154,159 @@ func (e *ErrorTracker) InitGoogleCloudErrorReporting(config GoogleCloudErrorRepo
 	if err != nil {
 		return fmt.Errorf("errtrack: cannot start Google Cloud Error Reporting %w", err)
 	}
-	e.errorExporters = append(e.errorExporters, gcloudExporter)
+	gcloudExporter.ClientIP = e.config.ClientIP
+	gcloudExporter.Redactor = e.config.Redactor
+	e.Register(gcloudExporter)
 	return nil
 }
 
-// CaptureError sends error to all other error trackers.
+// CaptureError queues the error to be sent to all the error trackers at the error level.
 func (e *ErrorTracker) CaptureError(err error, tags map[string]string, context map[string]interface{}) {
-	for _, e := range e.errorExporters {
-		e.CaptureError(err, tags, context)
+	e.CaptureErrorLevel(LevelError, err, tags, context)
+}
+
+// CaptureErrorLevel queues the error to be sent to all the error trackers at the given level.
+func (e *ErrorTracker) CaptureErrorLevel(level Level, err error, tags map[string]string, context map[string]interface{}) {
+	level, ok := e.classify(level, err, nil)
+	if !ok {
+		return
 	}
+	c := newCapture(level, err, tags, context, e.config.Redactor)
+	if !e.allow(c) {
+		return
+	}
+	e.push(c)
 }
 
-// CaptureHTTPError sends error to all other error trackers.
+// CaptureHTTPError queues the error to be sent to all the error trackers along with the request data,
+// at the error level. The request body is restored, so it can still be read after the call.
 func (e *ErrorTracker) CaptureHTTPError(err error, r *http.Request, tags map[string]string, context map[string]interface{}) {
-	for _, e := range e.errorExporters {
-		e.CaptureHTTPError(err, r, tags, context)
+	e.CaptureHTTPErrorLevel(LevelError, err, r, tags, context)
+}
+
+// CaptureHTTPErrorLevel queues the error to be sent to all the error trackers along with the request
+// data, at the given level. The request body is restored, so it can still be read after the call.
+func (e *ErrorTracker) CaptureHTTPErrorLevel(level Level, err error, r *http.Request, tags map[string]string, context map[string]interface{}) {
+	level, ok := e.classify(level, err, r)
+	if !ok {
+		return
+	}
+	c := newCapture(level, err, tags, context, e.config.Redactor)
+	if !e.allow(c) {
+		return
+	}
+	if r != nil {
+		c.setRequest(r, e.config.Redactor)
+	}
+	e.push(c)
+}
+
+// classify returns the level the error is sent at, downgraded to a warning by the
+// Warn decision, and whether it is sent at all.
+func (e *ErrorTracker) classify(level Level, err error, r *http.Request) (Level, bool) {
+	switch e.Classify(err, r) {
+	case LogOnly:
+		return level, false
+	case Warn:
+		if rank(level) > rank(LevelWarning) {
+			level = LevelWarning
+		}
+	}
+	return level, true
+}
+
+// Classify returns the decision of the configured Classifier for err, captured along with
+// the request r, which can be nil. The errors to LogOnly are not sent to the exporters.
+func (e *ErrorTracker) Classify(err error, r *http.Request) Decision {
+	return e.config.Classifier.Classify(err, r)
+}
+
+// allow reports whether the capture passes the deduplication and the rate limits.
+func (e *ErrorTracker) allow(c capture) bool {
+	if e.dedup == nil {
+		return true
 	}
+	return e.dedup.allow(fingerprint(c.err, callerFrame()), c)
 }
 
-// Close calls each children Close.
+// summarize sends the summaries of the suppressed duplicates at the end of every period.
+func (e *ErrorTracker) summarize(period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, c := range e.dedup.flush() {
+				e.push(c)
+			}
+		case <-e.done:
+			return
+		}
+	}
+}
+
+func (e *ErrorTracker) push(c capture) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.closed {
+		return
+	}
+	for _, q := range e.queues {
+		q.push(c)
+	}
+}
+
+// Dropped returns the number of errors that have not been sent because an
+// exporter queue was full or Close timed out.
+func (e *ErrorTracker) Dropped() uint64 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	var dropped uint64
+	for _, q := range e.queues {
+		dropped += q.dropped.Load()
+	}
+	return dropped
+}
+
+// Close waits up to the configured CloseTimeout for the queued errors to be sent,
+// then calls each children Close. Errors captured after Close are discarded.
 func (e *ErrorTracker) Close() {
-	for _, e := range e.errorExporters {
-		e.Close()
+	e.closeOnce.Do(e.close)
+}
+
+func (e *ErrorTracker) close() {
+	if e.dedup != nil {
+		close(e.done)
+		for _, c := range e.dedup.flush() {
+			e.push(c)
+		}
+	}
+
+	e.mu.Lock()
+	e.closed = true
+	for _, q := range e.queues {
+		close(q.captures)
+	}
+	e.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		for _, q := range e.queues {
+			q.wg.Wait()
+		}
+		close(done)
+	}()
+	timer := time.NewTimer(e.config.CloseTimeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		for _, q := range e.queues {
+			close(q.stop)
+		}
+	}
+
+	for _, q := range e.queues {
+		q.exporter.Close()
 	}
 }
@@ -0,0 +1,145 @@
+package errtrack_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	obserrors "github.com/JoinVerse/obs/errors"
+	"github.com/JoinVerse/obs/errtrack"
+	"github.com/JoinVerse/obs/errtrack/errtracktest"
+	"github.com/JoinVerse/obs/errtrack/gcp"
+	"github.com/JoinVerse/obs/errtrack/noop"
+	"github.com/JoinVerse/obs/errtrack/sentry"
+	"github.com/JoinVerse/obs/redact"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	_ errtrack.Exporter = (*noop.Exporter)(nil)
+	_ errtrack.Exporter = (*sentry.Exporter)(nil)
+	_ errtrack.Exporter = (*gcp.Exporter)(nil)
+
+	_ errtrack.LevelExporter = (*noop.Exporter)(nil)
+	_ errtrack.LevelExporter = (*sentry.Exporter)(nil)
+	_ errtrack.LevelExporter = (*gcp.Exporter)(nil)
+)
+
+func TestRegister(t *testing.T) {
+	rec := &errtracktest.Recorder{}
+	tracker := errtrack.New()
+	tracker.Register(rec)
+
+	err := errors.New("errtrack: boom")
+	tracker.CaptureError(err, map[string]string{"key": "value"}, nil)
+	tracker.Close()
+
+	assert.Equal(t, []error{err}, rec.Errors())
+	assert.True(t, rec.Closed(), "Registered exporter must be closed")
+}
+
+// blockingExporter blocks every capture until release is closed.
+type blockingExporter struct {
+	errtracktest.Recorder
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func newBlockingExporter() *blockingExporter {
+	return &blockingExporter{started: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (b *blockingExporter) CaptureErrorLevel(level errtrack.Level, err error, tags map[string]string, context map[string]interface{}) {
+	b.once.Do(func() { close(b.started) })
+	<-b.release
+	b.Recorder.CaptureErrorLevel(level, err, tags, context)
+}
+
+func TestCaptureDoesNotBlock(t *testing.T) {
+	exporter := newBlockingExporter()
+	tracker := errtrack.NewWithConfig(errtrack.Config{QueueSize: 2})
+	tracker.Register(exporter)
+
+	tracker.CaptureError(errors.New("errtrack: boom"), nil, nil)
+	<-exporter.started
+	for i := 0; i < 9; i++ {
+		tracker.CaptureError(errors.New("errtrack: boom"), nil, nil)
+	}
+	close(exporter.release)
+	tracker.Close()
+
+	// One capture is held by the worker, two are queued and the rest are dropped.
+	assert.Len(t, exporter.Errors(), 3)
+	assert.Equal(t, uint64(7), tracker.Dropped())
+}
+
+func TestCloseTimeout(t *testing.T) {
+	exporter := newBlockingExporter()
+	tracker := errtrack.NewWithConfig(errtrack.Config{CloseTimeout: 10 * time.Millisecond})
+	tracker.Register(exporter)
+
+	tracker.CaptureError(errors.New("errtrack: first"), nil, nil)
+	tracker.CaptureError(errors.New("errtrack: second"), nil, nil)
+	tracker.Close()
+	close(exporter.release)
+
+	assert.True(t, exporter.Closed(), "Exporter must be closed after the timeout")
+}
+
+func TestCaptureHTTPErrorRestoresBody(t *testing.T) {
+	rec := &errtracktest.Recorder{}
+	tracker := errtrack.New()
+	tracker.Register(rec)
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"key":"value"}`))
+	tracker.CaptureHTTPError(errors.New("errtrack: boom"), r, nil, nil)
+	body, _ := io.ReadAll(r.Body)
+	tracker.Close()
+
+	assert.Equal(t, `{"key":"value"}`, string(body))
+	if assert.Len(t, rec.Captures(), 1) {
+		captured, _ := io.ReadAll(rec.Captures()[0].Request.Body)
+		assert.Equal(t, `{"key":"value"}`, string(captured))
+	}
+}
+
+func TestCaptureHTTPErrorRedactsRequest(t *testing.T) {
+	rec := &errtracktest.Recorder{}
+	tracker := errtrack.NewWithConfig(errtrack.Config{Redactor: redact.New(redact.DefaultConfig())})
+	tracker.Register(rec)
+
+	r := httptest.NewRequest(http.MethodPost, "/login?token=abc", strings.NewReader(`{"user":"jane","password":"hunter2"}`))
+	r.Header.Set("Authorization", "Bearer abc")
+	tracker.CaptureHTTPError(errors.New("errtrack: boom"), r, nil, nil)
+	tracker.Close()
+
+	if assert.Len(t, rec.Captures(), 1) {
+		req := rec.Captures()[0].Request
+		body, _ := io.ReadAll(req.Body)
+		assert.JSONEq(t, `{"user":"jane","password":"[REDACTED]"}`, string(body))
+		assert.Equal(t, redact.Mask, req.Header.Get("Authorization"))
+		assert.Equal(t, "token=%5BREDACTED%5D", req.URL.RawQuery)
+		assert.Equal(t, "/login?token=%5BREDACTED%5D", req.RequestURI)
+	}
+	assert.Equal(t, "Bearer abc", r.Header.Get("Authorization"), "Original request must not change")
+}
+
+func TestCaptureErrorMergesErrorTags(t *testing.T) {
+	rec := &errtracktest.Recorder{}
+	tracker := errtrack.New()
+	tracker.Register(rec)
+
+	err := obserrors.WithTags(obserrors.New("errtrack: boom"), map[string]string{"order_id": "42", "key": "error"})
+	tracker.CaptureError(err, map[string]string{"key": "call"}, nil)
+	tracker.Close()
+
+	if assert.Len(t, rec.Captures(), 1) {
+		assert.Equal(t, map[string]string{"order_id": "42", "key": "call"}, rec.Captures()[0].Tags)
+	}
+}
@@ -0,0 +1,96 @@
+// Package errtracktest provides an exporter recording the captured errors, to test the
+// code reporting errors to an errtrack.ErrorTracker.
+package errtracktest
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/JoinVerse/obs/errtrack"
+)
+
+var _ errtrack.LevelExporter = (*Recorder)(nil)
+
+// Capture is an error received by the Recorder.
+type Capture struct {
+	Err error
+	// Level is the level of the error, LevelError when the exporter is not called with one.
+	Level errtrack.Level
+	// Request is the request of the errors captured with CaptureHTTPError, otherwise nil.
+	Request *http.Request
+	Tags    map[string]string
+	Context map[string]interface{}
+}
+
+// Recorder is an errtrack.LevelExporter keeping the captured errors in memory. The zero
+// value is ready to use and it is safe for concurrent use.
+type Recorder struct {
+	mu       sync.Mutex
+	captures []Capture
+	closed   bool
+}
+
+// CaptureError records the error.
+func (r *Recorder) CaptureError(err error, tags map[string]string, context map[string]interface{}) {
+	r.CaptureErrorLevel(errtrack.LevelError, err, tags, context)
+}
+
+// CaptureHTTPError records the error and the request.
+func (r *Recorder) CaptureHTTPError(err error, req *http.Request, tags map[string]string, context map[string]interface{}) {
+	r.CaptureHTTPErrorLevel(errtrack.LevelError, err, req, tags, context)
+}
+
+// CaptureErrorLevel records the error and its level.
+func (r *Recorder) CaptureErrorLevel(level errtrack.Level, err error, tags map[string]string, context map[string]interface{}) {
+	r.CaptureHTTPErrorLevel(level, err, nil, tags, context)
+}
+
+// CaptureHTTPErrorLevel records the error, its level and the request.
+func (r *Recorder) CaptureHTTPErrorLevel(level errtrack.Level, err error, req *http.Request, tags map[string]string, context map[string]interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.captures = append(r.captures, Capture{Err: err, Level: level, Request: req, Tags: tags, Context: context})
+}
+
+// Close records that the exporter has been closed.
+func (r *Recorder) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+}
+
+// Captures returns the recorded errors, in the order they have been received.
+func (r *Recorder) Captures() []Capture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Capture(nil), r.captures...)
+}
+
+// Errors returns the recorded errors, in the order they have been received.
+func (r *Recorder) Errors() []error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	errs := make([]error, len(r.captures))
+	for i, c := range r.captures {
+		errs[i] = c.Err
+	}
+	return errs
+}
+
+// Levels returns the levels of the recorded errors, in the order they have been received.
+func (r *Recorder) Levels() []errtrack.Level {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	levels := make([]errtrack.Level, len(r.captures))
+	for i, c := range r.captures {
+		levels[i] = c.Level
+	}
+	return levels
+}
+
+// Closed reports whether the exporter has been closed.
+func (r *Recorder) Closed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closed
+}
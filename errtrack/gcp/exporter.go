@@ -2,16 +2,32 @@ package gcp
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
+	"strings"
 
 	"cloud.google.com/go/errorreporting"
+	"github.com/JoinVerse/obs/clientip"
+	"github.com/JoinVerse/obs/errtrack/severity"
+	"github.com/JoinVerse/obs/redact"
 )
 
 // Exporter implements sending reports to google cloud.
+//
+// Error Reporting entries have no severity nor labels: the levels other than error are
+// only a severity tag, appended to the message with the request_id and trace_id tags.
+// The tags are on the line after the error message, which Error Reporting uses as the
+// title, but entries with different tags can still be grouped apart.
 type Exporter struct {
 	errorClient *errorreporting.Client
 	ctx         context.Context
+	// ClientIP resolves the remote IP address reported with the request. Defaults
+	// to a resolver trusting the private networks.
+	ClientIP *clientip.Resolver
+	// Redactor masks the sensitive data of the tags appended to the error message. It
+	// can be nil.
+	Redactor *redact.Redactor
 
 	getUserFn func(r *http.Request) string
 }
@@ -35,27 +51,45 @@ func New(ctx context.Context, projectID string, serviceName string, serviceVersi
 	return &Exporter{errorClient: errorClient, ctx: ctx, getUserFn: getUserFn}, nil
 }
 
-// Close shutdowns the Google cloud error tracker.
+// Close flushes the pending reports and shutdowns the Google cloud error tracker.
 func (e *Exporter) Close() {
 	_ = e.errorClient.Close()
 }
 
 // CaptureError send error to Google Cloud's Stack Driver.
 func (e *Exporter) CaptureError(err error, tags map[string]string, context map[string]interface{}) {
+	e.CaptureErrorLevel(severity.Error, err, tags, context)
+}
+
+// CaptureErrorLevel send error to Google Cloud's Stack Driver at the given level: debug,
+// info, warning, error or fatal. The level is only sent as a severity tag.
+func (e *Exporter) CaptureErrorLevel(level severity.Level, err error, tags map[string]string, context map[string]interface{}) {
 	e.errorClient.Report(errorreporting.Entry{
-		Error: err,
+		Error: withTags(err, withSeverity(tags, level), e.Redactor),
+		Stack: stack(err),
 	})
-	e.errorClient.Flush()
 }
 
 // CaptureHTTPError send error to Google Cloud's Stack Driver.
 func (e *Exporter) CaptureHTTPError(err error, r *http.Request, tags map[string]string, context map[string]interface{}) {
+	e.CaptureHTTPErrorLevel(severity.Error, err, r, tags, context)
+}
+
+// CaptureHTTPErrorLevel send error to Google Cloud's Stack Driver at the given level: debug,
+// info, warning, error or fatal. The level is only sent as a severity tag.
+func (e *Exporter) CaptureHTTPErrorLevel(level severity.Level, err error, r *http.Request, tags map[string]string, context map[string]interface{}) {
+	if r != nil {
+		// Error Reporting reads the remote IP from RemoteAddr.
+		ip := e.ClientIP.ClientIP(r)
+		r = r.WithContext(r.Context())
+		r.RemoteAddr = ip
+	}
 	e.errorClient.Report(errorreporting.Entry{
-		Error: err,
+		Error: withTags(err, withSeverity(tags, level), e.Redactor),
 		Req:   r,
 		User:  e.getUser(r),
+		Stack: stack(err),
 	})
-	e.errorClient.Flush()
 }
 
 func (e *Exporter) getUser(r *http.Request) string {
@@ -68,3 +102,74 @@ func (e *Exporter) getUser(r *http.Request) string {
 	}
 	return user
 }
+
+// stack returns the stack trace carried by err in runtime/debug.Stack format, if any,
+// so the report shows where the error was created instead of where it was captured.
+// Errors of the chain with an empty stack are skipped.
+func stack(err error) []byte {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if s, ok := err.(interface{ Stack() []byte }); ok && len(s.Stack()) > 0 {
+			return s.Stack()
+		}
+	}
+	return nil
+}
+
+// severities maps the levels to the Cloud Logging severities.
+var severities = map[severity.Level]string{
+	severity.Debug:   "DEBUG",
+	severity.Info:    "INFO",
+	severity.Warning: "WARNING",
+	severity.Fatal:   "CRITICAL",
+}
+
+// withSeverity adds the Cloud Logging severity of the level to the tags, since Error
+// Reporting entries have no severity. Errors keep their tags unchanged.
+func withSeverity(tags map[string]string, level severity.Level) map[string]string {
+	severity, ok := severities[level]
+	if !ok {
+		return tags
+	}
+	withSeverity := make(map[string]string, len(tags)+1)
+	for k, v := range tags {
+		withSeverity[k] = v
+	}
+	withSeverity["severity"] = severity
+	return withSeverity
+}
+
+// messageTags are the tags appended to the error message, since Error Reporting entries
+// have no labels. The other tags are not sent, they would make every message unique.
+var messageTags = []string{"request_id", "trace_id", "severity"}
+
+// taggedError appends the message tags on a line after the error message, so the first
+// line, used as the title of the report, does not change with every request.
+type taggedError struct {
+	error
+	tags string
+}
+
+func (e *taggedError) Error() string {
+	return e.error.Error() + "\n" + e.tags
+}
+
+func (e *taggedError) Unwrap() error {
+	return e.error
+}
+
+// withTags returns err with the message tags, whose values are masked by the redactor.
+func withTags(err error, tags map[string]string, redactor *redact.Redactor) error {
+	if err == nil {
+		return err
+	}
+	var pairs []string
+	for _, k := range messageTags {
+		if v, ok := tags[k]; ok {
+			pairs = append(pairs, k+"="+redactor.String(v))
+		}
+	}
+	if len(pairs) == 0 {
+		return err
+	}
+	return &taggedError{error: err, tags: strings.Join(pairs, " ")}
+}
@@ -0,0 +1,25 @@
+package gcp
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/JoinVerse/obs/errtrack/severity"
+	"github.com/JoinVerse/obs/redact"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithTags(t *testing.T) {
+	err := errors.New("db: timeout")
+	tags := map[string]string{
+		"request_id": "jane@example.com",
+		"trace_id":   "4bf92f3577b34da6a3ce929d0e0e4736",
+		"user_id":    "42",
+		"key":        "value",
+	}
+
+	tagged := withTags(err, withSeverity(tags, severity.Warning), redact.New(redact.DefaultConfig()))
+	assert.EqualError(t, tagged, "db: timeout\nrequest_id=[REDACTED] trace_id=4bf92f3577b34da6a3ce929d0e0e4736 severity=WARNING")
+	assert.ErrorIs(t, tagged, err)
+	assert.Equal(t, err, withTags(err, map[string]string{"user_id": "42"}, nil))
+}
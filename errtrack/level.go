@@ -0,0 +1,53 @@
+package errtrack
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/JoinVerse/obs/errtrack/severity"
+)
+
+// Level is the severity of a captured error. It is defined in the severity package, so
+// the exporters can use it.
+type Level = severity.Level
+
+const (
+	LevelDebug   = severity.Debug
+	LevelInfo    = severity.Info
+	LevelWarning = severity.Warning
+	LevelError   = severity.Error
+	LevelFatal   = severity.Fatal
+)
+
+// ParseLevel returns the Level of its name.
+func ParseLevel(name string) (Level, error) {
+	switch level := Level(name); level {
+	case LevelDebug, LevelInfo, LevelWarning, LevelError, LevelFatal:
+		return level, nil
+	}
+	return "", fmt.Errorf("errtrack: unknown level %q", name)
+}
+
+// rank orders the levels by severity. Unknown levels are errors.
+func rank(l Level) int {
+	switch l {
+	case LevelDebug:
+		return 0
+	case LevelInfo:
+		return 1
+	case LevelWarning:
+		return 2
+	case LevelFatal:
+		return 4
+	}
+	return 3
+}
+
+// LevelExporter is implemented by the exporters supporting severity levels. The level
+// is one of the Level constants. Exporters not implementing it receive the errors of
+// every level through the Exporter methods.
+type LevelExporter interface {
+	Exporter
+	CaptureErrorLevel(level Level, err error, tags map[string]string, context map[string]interface{})
+	CaptureHTTPErrorLevel(level Level, err error, r *http.Request, tags map[string]string, context map[string]interface{})
+}
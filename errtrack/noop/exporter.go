@@ -1,8 +1,13 @@
 package noop
 
-import "net/http"
+import (
+	"net/http"
 
-// Exporter does nothing but implements the Exporter interface.
+	"github.com/JoinVerse/obs/errtrack/severity"
+)
+
+// Exporter does nothing but implements the errtrack.Exporter interface.
+// It can be used as a reference implementation or for testing purposes.
 type Exporter struct{}
 
 // New creates new noop.Exporter.
@@ -14,7 +19,15 @@ func New() *Exporter {
 func (*Exporter) CaptureError(err error, tags map[string]string, context map[string]interface{}) {}
 
 // CaptureHTTPError send error to nowhere.
-func (*Exporter) CaptureHTTPError(err error, r http.Request, tags map[string]string, context map[string]interface{}) {
+func (*Exporter) CaptureHTTPError(err error, r *http.Request, tags map[string]string, context map[string]interface{}) {
+}
+
+// CaptureErrorLevel send error to nowhere.
+func (*Exporter) CaptureErrorLevel(level severity.Level, err error, tags map[string]string, context map[string]interface{}) {
+}
+
+// CaptureHTTPErrorLevel send error to nowhere.
+func (*Exporter) CaptureHTTPErrorLevel(level severity.Level, err error, r *http.Request, tags map[string]string, context map[string]interface{}) {
 }
 
 // Close does nothing.
@@ -0,0 +1,47 @@
+package errtrack
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// PanicError is the error built from a recovered panic. It keeps the stack trace
+// of the goroutine that panicked, so trackers can show where the panic happened.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value interface{}
+	pcs   []uintptr
+	stack []byte
+}
+
+// NewPanicError returns a PanicError for the recovered value. It must be called
+// from the deferred function that recovered the panic to capture the right stack.
+func NewPanicError(value interface{}) *PanicError {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(2, pcs)
+	return &PanicError{Value: value, pcs: pcs[:n], stack: debug.Stack()}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value when it is an error.
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// StackTrace returns the program counters of the panicking goroutine, the format
+// used by Sentry to extract the stack frames.
+func (e *PanicError) StackTrace() []uintptr {
+	return e.pcs
+}
+
+// Stack returns the stack trace of the panicking goroutine formatted by runtime/debug.Stack.
+func (e *PanicError) Stack() []byte {
+	return e.stack
+}
@@ -0,0 +1,212 @@
+package errtrack
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	obserrors "github.com/JoinVerse/obs/errors"
+	"github.com/JoinVerse/obs/redact"
+)
+
+// maxBodySnapshot is the maximum number of request body bytes kept to be sent
+// along with an HTTP error, since the request is handled asynchronously.
+const maxBodySnapshot = 64 << 10
+
+// capture holds everything needed to send an error once it leaves the caller goroutine.
+type capture struct {
+	err   error
+	level Level
+	// stack is the stack trace of the capture site, when err has none.
+	stack   []uintptr
+	req     *http.Request
+	body    []byte
+	tags    map[string]string
+	context map[string]interface{}
+}
+
+// request returns a copy of the captured request, safe to be used by a single exporter.
+func (c *capture) request() *http.Request {
+	if c.req == nil {
+		return nil
+	}
+	r := c.req.Clone(context.Background())
+	if c.body != nil {
+		r.Body = io.NopCloser(bytes.NewReader(c.body))
+	} else {
+		r.Body = http.NoBody
+	}
+	return r
+}
+
+// newCapture snapshots the error data so that the caller can keep using it.
+// The sensitive data of the context is masked by the redactor, which can be nil.
+// The tags and context carried by err are merged, the ones given at the call site take precedence.
+// The stack trace of the capture site is kept for the errors without one.
+func newCapture(level Level, err error, tags map[string]string, context map[string]interface{}, redactor *redact.Redactor) capture {
+	stack := callerStack(err)
+	tags = mergeTags(obserrors.Tags(err), tags)
+	context = mergeContext(obserrors.Context(err), context)
+	return capture{err: err, level: level, stack: stack, tags: copyTags(tags), context: redactor.Map(copyContext(context))}
+}
+
+// mergeTags returns the tags of base overridden by the ones of override.
+func mergeTags(base, override map[string]string) map[string]string {
+	if len(base) == 0 {
+		return override
+	}
+	merged := copyTags(base)
+	for k, v := range override {
+		merged[k] = v
+	}
+	return merged
+}
+
+// mergeContext returns the context of base overridden by the one of override.
+func mergeContext(base, override map[string]interface{}) map[string]interface{} {
+	if len(base) == 0 {
+		return override
+	}
+	merged := copyContext(base)
+	for k, v := range override {
+		merged[k] = v
+	}
+	return merged
+}
+
+// setRequest snapshots the request, including up to maxBodySnapshot bytes of its body.
+// The sensitive data of the url, headers and body is masked by the redactor, which can be nil.
+func (c *capture) setRequest(r *http.Request, redactor *redact.Redactor) {
+	c.req = r.Clone(context.Background())
+	c.req.URL = redactor.URL(r.URL)
+	// Exporters, like GCP Error Reporting, may build the URL from RequestURI.
+	c.req.RequestURI = c.req.URL.RequestURI()
+	c.req.Header = redactor.Header(r.Header)
+	if r.Body != nil && r.Body != http.NoBody {
+		body, _ := io.ReadAll(io.LimitReader(r.Body, maxBodySnapshot))
+		// Restore the io.ReadCloser so the caller can still read the whole body.
+		r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		c.body = redactor.Bytes(body)
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	if tags == nil {
+		return nil
+	}
+	c := make(map[string]string, len(tags))
+	for k, v := range tags {
+		c[k] = v
+	}
+	return c
+}
+
+func copyContext(context map[string]interface{}) map[string]interface{} {
+	if context == nil {
+		return nil
+	}
+	c := make(map[string]interface{}, len(context))
+	for k, v := range context {
+		c[k] = v
+	}
+	return c
+}
+
+// exporterQueue is the bounded queue of captures pending to be sent by an exporter.
+type exporterQueue struct {
+	exporter Exporter
+	policy   DropPolicy
+	captures chan capture
+	stop     chan struct{}
+	wg       sync.WaitGroup
+	dropped  atomic.Uint64
+}
+
+func newExporterQueue(exporter Exporter, config Config) *exporterQueue {
+	q := &exporterQueue{
+		exporter: exporter,
+		policy:   config.DropPolicy,
+		captures: make(chan capture, config.QueueSize),
+		stop:     make(chan struct{}),
+	}
+	q.wg.Add(config.Workers)
+	for i := 0; i < config.Workers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+// push enqueues the capture applying the drop policy when the queue is full.
+func (q *exporterQueue) push(c capture) {
+	switch q.policy {
+	case Block:
+		q.captures <- c
+		return
+	case DropOldest:
+		for {
+			select {
+			case q.captures <- c:
+				return
+			default:
+			}
+			select {
+			case <-q.captures:
+				q.dropped.Add(1)
+			default:
+			}
+		}
+	default:
+		select {
+		case q.captures <- c:
+		default:
+			q.dropped.Add(1)
+		}
+	}
+}
+
+func (q *exporterQueue) work() {
+	defer q.wg.Done()
+	for c := range q.captures {
+		select {
+		case <-q.stop:
+			q.dropped.Add(1)
+			continue
+		default:
+		}
+		q.send(c)
+	}
+}
+
+func (q *exporterQueue) send(c capture) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("errtrack: exporter %T panicked: %v", q.exporter, r)
+		}
+	}()
+	err := c.err
+	if c.stack != nil && reportsStack(q.exporter) {
+		err = &stackError{error: err, pcs: c.stack}
+	}
+	if e, ok := q.exporter.(LevelExporter); ok {
+		if c.req != nil {
+			e.CaptureHTTPErrorLevel(c.level, err, c.request(), c.tags, c.context)
+			return
+		}
+		e.CaptureErrorLevel(c.level, err, c.tags, c.context)
+		return
+	}
+	if c.req != nil {
+		q.exporter.CaptureHTTPError(err, c.request(), c.tags, c.context)
+		return
+	}
+	q.exporter.CaptureError(err, c.tags, c.context)
+}
@@ -5,13 +5,25 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/JoinVerse/obs/clientip"
+	obserrors "github.com/JoinVerse/obs/errors"
+	"github.com/JoinVerse/obs/errtrack/severity"
 	"github.com/getsentry/sentry-go"
 )
 
 type User sentry.User
 
+// Tags holding the ids of the trace the error belongs to.
+const (
+	traceIDTag = "trace_id"
+	spanIDTag  = "span_id"
+)
+
 // Exporter implements sending reports to sentry.
 type Exporter struct {
+	// ClientIP resolves the IP address of the user. Defaults to a resolver trusting
+	// the private networks.
+	ClientIP  *clientip.Resolver
 	getUserFn func(r *http.Request) User
 }
 
@@ -37,17 +49,33 @@ func (e *Exporter) Close() {
 
 // CaptureError send error to Sentry.
 func (e *Exporter) CaptureError(err error, tags map[string]string, context map[string]interface{}) {
+	e.CaptureErrorLevel(severity.Error, err, tags, context)
+}
+
+// CaptureErrorLevel send error to Sentry at the given level: debug, info, warning, error or fatal.
+func (e *Exporter) CaptureErrorLevel(level severity.Level, err error, tags map[string]string, context map[string]interface{}) {
 	sentry.WithScope(func(scope *sentry.Scope) {
+		scope.SetLevel(sentry.Level(level))
 		scope.SetTags(tags)
 		scope.SetContext("context", context)
+		setTrace(scope, tags)
+		if fingerprint := obserrors.Fingerprint(err); len(fingerprint) > 0 {
+			scope.SetFingerprint(fingerprint)
+		}
 		sentry.CaptureException(err)
 	})
 }
 
 // CaptureHTTPError send error to Sentry.
 func (e *Exporter) CaptureHTTPError(err error, r *http.Request, tags map[string]string, context map[string]interface{}) {
+	e.CaptureHTTPErrorLevel(severity.Error, err, r, tags, context)
+}
+
+// CaptureHTTPErrorLevel send error to Sentry at the given level: debug, info, warning, error or fatal.
+func (e *Exporter) CaptureHTTPErrorLevel(level severity.Level, err error, r *http.Request, tags map[string]string, context map[string]interface{}) {
 	user := e.getUser(r)
 	sentry.WithScope(func(scope *sentry.Scope) {
+		scope.SetLevel(sentry.Level(level))
 		scope.SetRequest(r)
 		// Adds r.Body explicitly because setRequest only set it at same time is read,
 		// so you MUST call SetRequest before read the body
@@ -59,6 +87,10 @@ func (e *Exporter) CaptureHTTPError(err error, r *http.Request, tags map[string]
 		scope.SetTags(tags)
 		scope.SetUser(sentry.User(user))
 		scope.SetContext("context", context)
+		setTrace(scope, tags)
+		if fingerprint := obserrors.Fingerprint(err); len(fingerprint) > 0 {
+			scope.SetFingerprint(fingerprint)
+		}
 		sentry.CaptureException(err)
 	})
 }
@@ -70,7 +102,19 @@ func (e *Exporter) getUser(r *http.Request) User {
 	var user User
 	if r != nil {
 		user.ID = r.Header.Get("X-User-Id")
-		user.IPAddress = r.Header.Get("X-Forwarder-For")
+		user.IPAddress = e.ClientIP.ClientIP(r)
 	}
 	return user
 }
+
+// setTrace sets the trace context Sentry uses to link the error with its trace.
+func setTrace(scope *sentry.Scope, tags map[string]string) {
+	traceID, ok := tags[traceIDTag]
+	if !ok {
+		return
+	}
+	scope.SetContext("trace", map[string]interface{}{
+		"trace_id": traceID,
+		"span_id":  tags[spanIDTag],
+	})
+}
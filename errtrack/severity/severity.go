@@ -0,0 +1,14 @@
+// Package severity defines the levels of the captured errors. It is imported by the
+// exporters, errtrack.Level is the same type.
+package severity
+
+// Level is the severity of a captured error.
+type Level string
+
+const (
+	Debug   Level = "debug"
+	Info    Level = "info"
+	Warning Level = "warning"
+	Error   Level = "error"
+	Fatal   Level = "fatal"
+)
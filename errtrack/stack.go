@@ -0,0 +1,78 @@
+package errtrack
+
+import (
+	"errors"
+	"runtime"
+
+	obserrors "github.com/JoinVerse/obs/errors"
+	"github.com/JoinVerse/obs/errtrack/gcp"
+	"github.com/JoinVerse/obs/errtrack/sentry"
+)
+
+// callerStack returns the stack trace of the first caller outside of this module, or
+// nil if err already carries a stack trace. The exporters run in the queue workers, so
+// the trackers would otherwise report the stack of the worker.
+func callerStack(err error) []uintptr {
+	if err == nil || len(stackTrace(err)) > 0 {
+		return nil
+	}
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(3, pcs)
+	pcs = pcs[:n]
+	frames := runtime.CallersFrames(pcs)
+	for i := 0; ; i++ {
+		frame, more := frames.Next()
+		if !isModuleFrame(frame.Function) {
+			return pcs[i:]
+		}
+		if !more {
+			return pcs
+		}
+	}
+}
+
+// reportsStack reports whether the exporter reports the stack trace of the errors. The
+// errors without one are sent to it carrying the stack trace of the capture site, the
+// other exporters receive the captured errors as is.
+func reportsStack(exporter Exporter) bool {
+	switch exporter.(type) {
+	case *sentry.Exporter, *gcp.Exporter:
+		return true
+	}
+	return false
+}
+
+// stackError is an error without a stack trace of its own, carrying the stack trace of
+// the capture site.
+type stackError struct {
+	error
+	pcs []uintptr
+}
+
+func (e *stackError) Unwrap() error {
+	return e.error
+}
+
+// StackTrace returns the program counters of the capture site, the format used by
+// Sentry to extract the stack frames.
+func (e *stackError) StackTrace() []uintptr {
+	return e.pcs
+}
+
+// Stack returns the stack trace of the capture site formatted like runtime/debug.Stack,
+// the format used by GCP Error Reporting.
+func (e *stackError) Stack() []byte {
+	return obserrors.FormatStack(e.pcs)
+}
+
+// stackTrace returns the first stack trace of the chain of err. Like Sentry, it does not
+// look into joined errors.
+func stackTrace(err error) []uintptr {
+	for err != nil {
+		if s, ok := err.(interface{ StackTrace() []uintptr }); ok && len(s.StackTrace()) > 0 {
+			return s.StackTrace()
+		}
+		err = errors.Unwrap(err)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package errtrack
+
+import (
+	"errors"
+	"testing"
+
+	obserrors "github.com/JoinVerse/obs/errors"
+	"github.com/JoinVerse/obs/errtrack/gcp"
+	"github.com/JoinVerse/obs/errtrack/noop"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaptureStack(t *testing.T) {
+	c := newCapture(LevelError, errors.New("errtrack: boom"), nil, nil, nil)
+	stack := string(obserrors.FormatStack(c.stack))
+	assert.NotContains(t, stack, "errtrack.newCapture", "The stack must be the one of the capture site")
+	assert.Contains(t, stack, "testing.tRunner")
+
+	assert.Nil(t, newCapture(LevelError, NewPanicError("boom"), nil, nil, nil).stack, "Errors with a stack must keep it")
+
+	assert.True(t, reportsStack(&gcp.Exporter{}))
+	assert.False(t, reportsStack(noop.New()), "Other exporters must receive the error as is")
+}
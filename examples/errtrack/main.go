@@ -16,9 +16,9 @@ func main() {
 	err := fmt.Errorf("main: ups, that was an error")
 	errorTracker.CaptureError(err, map[string]string{"key": "value"}, nil)
 
-	// You can use the noopExporter exporter for testing purposes, it does nothing
-	noopExporter := noop.New()
-	defer noopExporter.Close()
-	noopExporter.CaptureError(err, map[string]string{"os": "Darwin"}, map[string]interface{}{"body": "{'ola':'ola2'}"})
+	// You can plug any errtrack.Exporter, the noop exporter does nothing
+	// and can be used for testing purposes or as a reference implementation.
+	errorTracker.Register(noop.New())
+	errorTracker.CaptureError(err, map[string]string{"os": "Darwin"}, map[string]interface{}{"body": "{'ola':'ola2'}"})
 
 }
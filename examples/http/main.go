@@ -32,9 +32,15 @@ func main() {
 		fmt.Fprintf(w, "ups\n")
 	})
 
+	panicHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("main: ups, something really wrong happened")
+	})
+
 	logger := hlog.New()
+	recoverer := hlog.Recoverer(observer.ErrorTracker(), hlog.RecovererConfig{})
 
 	http.Handle("/", logger.Handler(okHandler))
+	http.Handle("/panic", logger.Handler(recoverer(panicHandler)))
 	// Use always logger.Handler hlog.Logger is deprecated keep it here for testing backward compatibility
 	http.Handle("/error", hlog.Logger(errorHandler))
 
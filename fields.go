@@ -0,0 +1,97 @@
+package obs
+
+import (
+	"time"
+)
+
+// Field is a key/value pair added to a log entry.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// String returns a Field with a string value.
+func String(key, value string) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Int returns a Field with an int value.
+func Int(key string, value int) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Int64 returns a Field with an int64 value.
+func Int64(key string, value int64) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Float64 returns a Field with a float64 value.
+func Float64(key string, value float64) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Bool returns a Field with a bool value.
+func Bool(key string, value bool) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Duration returns a Field with a time.Duration value.
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Time returns a Field with a time.Time value.
+func Time(key string, value time.Time) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Strings returns a Field with a []string value.
+func Strings(key string, value []string) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Any returns a Field with any value, it is marshaled as JSON.
+func Any(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// fieldList converts fields to the key/value list expected by zerolog.
+func fieldList(fields []Field) []interface{} {
+	kv := make([]interface{}, 0, 2*len(fields))
+	for _, f := range fields {
+		kv = append(kv, f.Key, f.Value)
+	}
+	return kv
+}
+
+// kvList converts a list of alternated keys and values to the list expected by zerolog.
+// Field values are expanded, so they can be mixed with plain key/value pairs.
+func kvList(kv []interface{}) []interface{} {
+	list := make([]interface{}, 0, len(kv))
+	for i := 0; i < len(kv); i++ {
+		if f, ok := kv[i].(Field); ok {
+			list = append(list, f.Key, f.Value)
+			continue
+		}
+		if i+1 < len(kv) {
+			list = append(list, kv[i], kv[i+1])
+			i++
+		}
+	}
+	return list
+}
+
+// kvMap converts a list of alternated keys and values to a map.
+func kvMap(kv []interface{}) map[string]interface{} {
+	list := kvList(kv)
+	if len(list) == 0 {
+		return nil
+	}
+	m := make(map[string]interface{}, len(list)/2)
+	for i := 0; i < len(list); i += 2 {
+		if key, ok := list[i].(string); ok {
+			m[key] = list[i+1]
+		}
+	}
+	return m
+}
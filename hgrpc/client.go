@@ -0,0 +1,81 @@
+package hgrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/JoinVerse/obs/hlog"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryClient returns the client interceptor of the unary calls. It propagates the
+// request id of ctx and logs the calls through the logger of ctx.
+func (i *Interceptors) UnaryClient() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(outgoing(ctx), method, req, reply, cc, opts...)
+		logClientCall(ctx, method, start, err)
+		return err
+	}
+}
+
+// StreamClient returns the client interceptor of the streaming calls. It propagates
+// the request id of ctx and logs the calls through the logger of ctx once the stream ends.
+func (i *Interceptors) StreamClient() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		cs, err := streamer(outgoing(ctx), desc, cc, method, opts...)
+		if err != nil {
+			logClientCall(ctx, method, start, err)
+			return nil, err
+		}
+		return &clientStream{ClientStream: cs, done: func(err error) { logClientCall(ctx, method, start, err) }}, nil
+	}
+}
+
+// clientStream logs the call when the stream ends.
+type clientStream struct {
+	grpc.ClientStream
+	once sync.Once
+	done func(err error)
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			s.once.Do(func() { s.done(nil) })
+		} else {
+			s.once.Do(func() { s.done(err) })
+		}
+	}
+	return err
+}
+
+// outgoing adds the request id of ctx to the outgoing metadata, unless it is already set.
+func outgoing(ctx context.Context) context.Context {
+	id, ok := hlog.RequestIDFromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md.Get(RequestIDKey)) > 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, RequestIDKey, id)
+}
+
+func logClientCall(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+	level, _ := severity(code)
+	zerolog.Ctx(ctx).WithLevel(level).
+		Dur("duration", time.Since(start)).
+		Dict("grpcClient", callDict(ctx, method, code)).
+		Err(err).
+		Msg("hgrpc: outgoing call")
+}
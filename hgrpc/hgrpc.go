@@ -0,0 +1,214 @@
+// Package hgrpc provides gRPC interceptors mirroring the hlog middlewares: access
+// logs, request ids, panic recovery and error capture.
+package hgrpc
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/JoinVerse/obs/errtrack"
+	"github.com/JoinVerse/obs/hlog"
+	"github.com/JoinVerse/obs/logformat"
+	"github.com/JoinVerse/obs/redact"
+	"github.com/rs/xid"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDKey is the metadata key carrying the request id.
+const RequestIDKey = "x-request-id"
+
+// Config handles Interceptors configuration.
+type Config struct {
+	// Writer is where the logs are written. Defaults to os.Stdout.
+	Writer io.Writer
+	// Format is the format of the logs. Defaults to the OBS_LOG_FORMAT environment
+	// variable or, if it is not set, to JSON.
+	Format logformat.Format
+	// ErrorTracker, when set, receives the recovered panics and the errors returned
+	// by the server handlers with one of the ReportCodes.
+	ErrorTracker *errtrack.ErrorTracker
+	// ReportCodes are the status codes of the errors sent to the ErrorTracker.
+	// Defaults to Unknown, Internal and DataLoss.
+	ReportCodes []codes.Code
+	// Redactor masks the sensitive metadata sent to the ErrorTracker. Defaults to
+	// redact.DefaultConfig. The credentials metadata are never sent.
+	Redactor *redact.Redactor
+}
+
+// Interceptors logs the gRPC calls and reports their errors, like hlog.LoggerZ.Handler,
+// hlog.RequestIDHeaderHandler and hlog.Recoverer do for net/http.
+type Interceptors struct {
+	zl     zerolog.Logger
+	config Config
+	report map[codes.Code]bool
+}
+
+// New creates the interceptors with the given configuration.
+func New(config Config) *Interceptors {
+	if config.Writer == nil {
+		config.Writer = os.Stdout
+	}
+	if config.Format == "" {
+		config.Format = logformat.FromEnv()
+	}
+	if config.ReportCodes == nil {
+		config.ReportCodes = []codes.Code{codes.Unknown, codes.Internal, codes.DataLoss}
+	}
+	if config.Redactor == nil {
+		config.Redactor = redact.New(redact.DefaultConfig())
+	}
+	report := make(map[codes.Code]bool, len(config.ReportCodes))
+	for _, c := range config.ReportCodes {
+		report[c] = true
+	}
+	host, _ := os.Hostname()
+	zl := zerolog.New(logformat.NewWriter(config.Format, config.Writer)).With().Timestamp().Str("host", host).Logger()
+	return &Interceptors{zl: zl, config: config, report: report}
+}
+
+// UnaryServer returns the server interceptor of the unary calls.
+func (i *Interceptors) UnaryServer() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		ctx, done := i.begin(ctx, info.FullMethod)
+		defer func() {
+			var panicErr *errtrack.PanicError
+			if v := recover(); v != nil {
+				panicErr = errtrack.NewPanicError(v)
+			}
+			done(panicErr, &err)
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServer returns the server interceptor of the streaming calls.
+func (i *Interceptors) StreamServer() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		ctx, done := i.begin(ss.Context(), info.FullMethod)
+		defer func() {
+			var panicErr *errtrack.PanicError
+			if v := recover(); v != nil {
+				panicErr = errtrack.NewPanicError(v)
+			}
+			done(panicErr, &err)
+		}()
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// serverStream overrides the context of the stream with the one holding the logger.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// begin installs the request-scoped logger and the request id in ctx. The returned
+// function turns the recovered panic, if any, into *err, reports the error and logs the call.
+func (i *Interceptors) begin(ctx context.Context, fullMethod string) (context.Context, func(panicErr *errtrack.PanicError, err *error)) {
+	start := time.Now()
+	md, _ := metadata.FromIncomingContext(ctx)
+	id := first(md, RequestIDKey)
+	if id == "" {
+		id = xid.New().String()
+	}
+	l := i.zl.With().Str("requestId", id).Logger()
+	ctx = hlog.WithRequestID(l.WithContext(ctx), id)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDKey, id))
+
+	return ctx, func(panicErr *errtrack.PanicError, err *error) {
+		if panicErr != nil {
+			zerolog.Ctx(ctx).Error().Err(panicErr).Str("stack", string(panicErr.Stack())).Msg("hgrpc: panic recovered")
+			i.capture(ctx, panicErr, fullMethod, md)
+			*err = status.Error(codes.Internal, codes.Internal.String())
+		} else if *err != nil && i.report[status.Code(*err)] {
+			i.capture(ctx, *err, fullMethod, md)
+		}
+		code := status.Code(*err)
+		level, severity := severity(code)
+		zerolog.Ctx(ctx).WithLevel(level).
+			Str("severity", severity).
+			Dur("duration", time.Since(start)).
+			Dict("grpc", callDict(ctx, fullMethod, code)).
+			Err(*err).
+			Msg("")
+	}
+}
+
+// capture sends err to the tracker with the request id, the peer and the metadata.
+func (i *Interceptors) capture(ctx context.Context, err error, fullMethod string, md metadata.MD) {
+	if i.config.ErrorTracker == nil {
+		return
+	}
+	tags := map[string]string{"grpc.method": fullMethod}
+	if id, ok := hlog.RequestIDFromContext(ctx); ok {
+		tags["request_id"] = id
+	}
+	extra := map[string]interface{}{
+		"metadata": map[string][]string(i.config.Redactor.Header(withoutCredentials(md))),
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		extra["peer"] = p.Addr.String()
+	}
+	i.config.ErrorTracker.CaptureError(err, tags, extra)
+}
+
+// credentialKeys are the metadata keys dropped before sending the metadata to the
+// ErrorTracker, like Sentry does with the HTTP headers.
+var credentialKeys = []string{"authorization", "cookie", "x-api-key"}
+
+// withoutCredentials returns a copy of md without the credentialKeys.
+func withoutCredentials(md metadata.MD) http.Header {
+	h := http.Header(md.Copy())
+	for _, k := range credentialKeys {
+		delete(h, k)
+	}
+	return h
+}
+
+// callDict returns the log fields of a call.
+func callDict(ctx context.Context, fullMethod string, code codes.Code) *zerolog.Event {
+	service, method := path.Split(fullMethod)
+	d := zerolog.Dict().
+		Str("service", strings.Trim(service, "/")).
+		Str("method", method).
+		Str("code", code.String())
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		d.Str("peer", p.Addr.String())
+	}
+	return d
+}
+
+// severity returns the log level and the GCP severity of a status code: client errors
+// are warnings and server errors are errors.
+func severity(code codes.Code) (zerolog.Level, string) {
+	switch code {
+	case codes.OK:
+		return zerolog.InfoLevel, "INFO"
+	case codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists,
+		codes.PermissionDenied, codes.Unauthenticated, codes.FailedPrecondition,
+		codes.OutOfRange, codes.ResourceExhausted, codes.Aborted:
+		return zerolog.WarnLevel, "WARNING"
+	}
+	return zerolog.ErrorLevel, "ERROR"
+}
+
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -0,0 +1,102 @@
+package hgrpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/JoinVerse/obs/errtrack"
+	"github.com/JoinVerse/obs/errtrack/errtracktest"
+	"github.com/JoinVerse/obs/hlog"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// healthServer answers depending on the checked service.
+type healthServer struct {
+	healthpb.UnimplementedHealthServer
+	requestID string
+}
+
+func (s *healthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	s.requestID, _ = hlog.RequestIDFromContext(ctx)
+	switch req.Service {
+	case "panic":
+		panic(errors.New("hgrpc: boom"))
+	case "fail":
+		return nil, status.Error(codes.Internal, "database is down")
+	case "missing":
+		return nil, status.Error(codes.NotFound, "unknown service")
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+func TestInterceptors(t *testing.T) {
+	rec := &errtracktest.Recorder{}
+	tracker := errtrack.New()
+	tracker.Register(rec)
+	out := &bytes.Buffer{}
+	interceptors := New(Config{Writer: out, ErrorTracker: tracker})
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(interceptors.UnaryServer()),
+		grpc.StreamInterceptor(interceptors.StreamServer()),
+	)
+	health := &healthServer{}
+	healthpb.RegisterHealthServer(server, health)
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(interceptors.UnaryClient()),
+	)
+	assert.Nil(t, err)
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	clientOut := &bytes.Buffer{}
+	clientLogger := zerolog.New(clientOut)
+	ctx := hlog.WithRequestID(clientLogger.WithContext(context.Background()), "req-1")
+	var header metadata.MD
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Header(&header))
+	assert.Nil(t, err)
+	assert.Equal(t, "req-1", health.requestID)
+	assert.Equal(t, []string{"req-1"}, header.Get(RequestIDKey))
+	assert.Contains(t, out.String(), `"requestId":"req-1"`)
+	assert.Contains(t, out.String(), `"grpc":{"service":"grpc.health.v1.Health","method":"Check","code":"OK"`)
+	assert.Contains(t, clientOut.String(), `"code":"OK"`)
+
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "fail"})
+	assert.Equal(t, codes.Internal, status.Code(err))
+	_, err = client.Check(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer abc"), &healthpb.HealthCheckRequest{Service: "panic"})
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Contains(t, out.String(), "hgrpc: panic recovered")
+	assert.Contains(t, out.String(), `"level":"warn"`)
+	tracker.Close()
+
+	if assert.Len(t, rec.Errors(), 2) {
+		assert.Equal(t, "database is down", status.Convert(rec.Errors()[0]).Message())
+		var panicErr *errtrack.PanicError
+		assert.ErrorAs(t, rec.Errors()[1], &panicErr)
+		assert.True(t, strings.Contains(string(panicErr.Stack()), "healthServer).Check"))
+		assert.Contains(t, rec.Captures()[1].Context, "peer")
+		if assert.Contains(t, rec.Captures()[1].Context, "metadata") {
+			assert.NotContains(t, rec.Captures()[1].Context["metadata"], "authorization", "Credentials must not be sent")
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package hlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/JoinVerse/obs/redact"
+	"github.com/rs/zerolog"
+)
+
+// defaultMaxBodySize is the default maximum number of body bytes logged.
+const defaultMaxBodySize = 64 << 10
+
+// truncatedMarker is appended to the logged bodies longer than the maximum size.
+const truncatedMarker = "...[truncated]"
+
+// BodyConfig handles RequestBodyHandler configuration.
+//
+// The body is captured while the handler reads it and added to the logger once the
+// handler returns: it is in the access log, but not in the lines logged, nor in the
+// errors captured, by the handler itself. The bodies the handler does not read are not
+// logged.
+type BodyConfig struct {
+	// Redactor, when set, masks the sensitive data of the body before it is logged.
+	Redactor *redact.Redactor
+	// MaxSize is the maximum number of body bytes logged, longer bodies are truncated.
+	// Defaults to 64KB.
+	MaxSize int64
+	// AllowContentTypes, when not empty, are the only media types whose body is logged.
+	// A type like "text/*" matches all its subtypes.
+	AllowContentTypes []string
+	// DenyContentTypes are the media types whose body is never logged.
+	// Defaults to "multipart/*" and "application/octet-stream".
+	DenyContentTypes []string
+	// Skip returns true for the requests whose body must not be logged.
+	Skip func(r *http.Request) bool
+}
+
+type bodyCaptureKey struct{}
+
+// bodyCapture keeps the first bytes of the body while the downstream handler reads it.
+type bodyCapture struct {
+	body      io.ReadCloser
+	buf       bytes.Buffer
+	max       int64
+	truncated bool
+	skip      bool
+}
+
+func (c *bodyCapture) Read(p []byte) (int, error) {
+	n, err := c.body.Read(p)
+	if n > 0 && !c.skip {
+		if room := c.max - int64(c.buf.Len()); room < int64(n) {
+			c.buf.Write(p[:max(room, 0)])
+			c.truncated = true
+		} else {
+			c.buf.Write(p[:n])
+		}
+	}
+	return n, err
+}
+
+func (c *bodyCapture) Close() error {
+	return c.body.Close()
+}
+
+// SkipRequestBody prevents the body of the request from being logged by RequestBodyHandler.
+// It can be called by a route handler to opt out, e.g. for uploads or credentials.
+func SkipRequestBody(r *http.Request) {
+	if c, ok := r.Context().Value(bodyCaptureKey{}).(*bodyCapture); ok {
+		c.skip = true
+	}
+}
+
+// RequestBodyHandler adds the requested Body as a field to the context's logger
+// using fieldKey as field key.
+func RequestBodyHandler(fieldKey string) func(next http.Handler) http.Handler {
+	return RequestBodyHandlerWithConfig(fieldKey, BodyConfig{})
+}
+
+// RequestBodyHandlerWithConfig adds the requested Body as a field to the context's logger
+// using fieldKey as field key, with the given configuration.
+//
+// The body is not buffered: up to MaxSize bytes are kept while the downstream handler
+// reads it, and the field is added once the handler returns. Truncated bodies are logged
+// as strings ending with "...[truncated]" and fieldKey+"Truncated" set to true.
+func RequestBodyHandlerWithConfig(fieldKey string, config BodyConfig) func(next http.Handler) http.Handler {
+	if config.MaxSize <= 0 {
+		config.MaxSize = defaultMaxBodySize
+	}
+	if config.DenyContentTypes == nil {
+		config.DenyContentTypes = []string{"multipart/*", "application/octet-stream"}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if r.Body == nil || r.Body == http.NoBody || !config.capture(r) {
+					next.ServeHTTP(w, r)
+					return
+				}
+				capture := &bodyCapture{body: r.Body, max: config.MaxSize}
+				r.Body = capture
+				r = r.WithContext(context.WithValue(r.Context(), bodyCaptureKey{}, capture))
+				next.ServeHTTP(w, r)
+
+				if capture.skip || capture.buf.Len() == 0 {
+					return
+				}
+				bodyBytes := config.Redactor.Bytes(capture.buf.Bytes())
+				log := zerolog.Ctx(r.Context())
+				log.UpdateContext(
+					func(c zerolog.Context) zerolog.Context {
+						if capture.truncated {
+							return c.Str(fieldKey, string(bodyBytes)+truncatedMarker).Bool(fieldKey+"Truncated", true)
+						}
+						if isJSON(bodyBytes) {
+							return c.RawJSON(fieldKey, bodyBytes)
+						}
+						return c.Bytes(fieldKey, bodyBytes)
+					},
+				)
+			},
+		)
+	}
+}
+
+// capture reports whether the body of the request must be logged.
+func (c BodyConfig) capture(r *http.Request) bool {
+	if c.Skip != nil && c.Skip(r) {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if matchMediaType(mediaType, c.DenyContentTypes) {
+		return false
+	}
+	return len(c.AllowContentTypes) == 0 || matchMediaType(mediaType, c.AllowContentTypes)
+}
+
+func matchMediaType(mediaType string, types []string) bool {
+	for _, t := range types {
+		t = strings.ToLower(t)
+		if prefix, ok := strings.CutSuffix(t, "*"); ok && strings.HasPrefix(mediaType, prefix) {
+			return true
+		}
+		if t == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+func isJSON(s []byte) bool {
+	var js map[string]interface{}
+	return json.Unmarshal(s, &js) == nil
+}
@@ -0,0 +1,89 @@
+package hlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+	"github.com/stretchr/testify/assert"
+)
+
+// serveBody sends a request with the given body and content type through RequestBodyHandlerWithConfig,
+// returning the logged fields and the body read by the downstream handler.
+func serveBody(t *testing.T, config BodyConfig, contentType, body string, next func(r *http.Request)) (map[string]interface{}, string) {
+	out := &bytes.Buffer{}
+	var read []byte
+	h := RequestBodyHandlerWithConfig("requestBody", config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if next != nil {
+			next(r)
+		}
+		read, _ = io.ReadAll(r.Body)
+	}))
+	h = hlog.AccessHandler(func(r *http.Request, status, size int, duration time.Duration) {
+		hlog.FromRequest(r).Log().Msg("")
+	})(h)
+	h = hlog.NewHandler(zerolog.New(out))(h)
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatalf("Invalid log line %q: %v", out.String(), err)
+	}
+	return line, string(read)
+}
+
+func TestBodyHandlerTruncation(t *testing.T) {
+	body := strings.Repeat("a", 20)
+	line, read := serveBody(t, BodyConfig{MaxSize: 8}, "text/plain", body, nil)
+
+	assert.Equal(t, body, read, "Downstream handler must read the whole body")
+	assert.Equal(t, "aaaaaaaa"+truncatedMarker, line["requestBody"])
+	assert.Equal(t, true, line["requestBodyTruncated"])
+}
+
+func TestBodyHandlerLogsAfterHandler(t *testing.T) {
+	out := &bytes.Buffer{}
+	h := RequestBodyHandlerWithConfig("requestBody", BodyConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		hlog.FromRequest(r).Info().Msg("handler")
+	}))
+	h = hlog.AccessHandler(func(r *http.Request, status, size int, duration time.Duration) {
+		hlog.FromRequest(r).Info().Msg("access")
+	})(h)
+	h = hlog.NewHandler(zerolog.New(out))(h)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"key":"value"}`)))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if assert.Len(t, lines, 2) {
+		assert.JSONEq(t, `{"level":"info","message":"handler"}`, lines[0], "The body is not known while the handler runs")
+		assert.JSONEq(t, `{"level":"info","requestBody":{"key":"value"},"message":"access"}`, lines[1])
+	}
+}
+
+func TestBodyHandlerContentTypes(t *testing.T) {
+	line, read := serveBody(t, BodyConfig{}, "application/octet-stream", "binary", nil)
+	assert.Equal(t, "binary", read)
+	assert.NotContains(t, line, "requestBody")
+
+	line, _ = serveBody(t, BodyConfig{AllowContentTypes: []string{"application/json"}}, "text/plain", "text", nil)
+	assert.NotContains(t, line, "requestBody")
+
+	line, _ = serveBody(t, BodyConfig{AllowContentTypes: []string{"text/*"}}, "text/plain; charset=utf-8", "text", nil)
+	assert.Equal(t, "text", line["requestBody"])
+}
+
+func TestSkipRequestBody(t *testing.T) {
+	line, read := serveBody(t, BodyConfig{}, "application/json", `{"password":"hunter2"}`, SkipRequestBody)
+	assert.Equal(t, `{"password":"hunter2"}`, read)
+	assert.NotContains(t, line, "requestBody")
+}
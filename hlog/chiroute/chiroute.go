@@ -0,0 +1,17 @@
+// Package chiroute extracts the route templates of the chi router for hlog.
+package chiroute
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Route returns the route pattern matched by chi, like "/users/{id}". LoggerZ.Handler
+// must be used as a chi middleware, with Router.Use, for the pattern to be available.
+func Route(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		return rctx.RoutePattern()
+	}
+	return ""
+}
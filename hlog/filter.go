@@ -0,0 +1,52 @@
+package hlog
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// FilterConfig selects the requests whose access log entry and body are not logged,
+// like health checks. A request is skipped when it matches any of the conditions.
+// Skipped requests are still recorded in the metrics.
+type FilterConfig struct {
+	// Paths are the path.Match patterns of the skipped paths, like "/healthz" or "/debug/*".
+	Paths []string
+	// Methods are the skipped methods, like "OPTIONS".
+	Methods []string
+	// UserAgents are the substrings of the skipped user agents, like "kube-probe/".
+	UserAgents []string
+	// Skip returns true for the requests that must not be logged.
+	Skip func(r *http.Request) bool
+}
+
+// HealthCheckFilter returns a FilterConfig skipping the usual health check paths and
+// the probes of Kubernetes and of the GCP and AWS load balancers.
+func HealthCheckFilter() FilterConfig {
+	return FilterConfig{
+		Paths:      []string{"/healthz", "/readyz", "/livez", "/health"},
+		UserAgents: []string{"kube-probe/", "GoogleHC/", "ELB-HealthChecker/"},
+	}
+}
+
+// skip reports whether the request must not be logged.
+func (c FilterConfig) skip(r *http.Request) bool {
+	for _, p := range c.Paths {
+		if ok, _ := path.Match(p, r.URL.Path); ok {
+			return true
+		}
+	}
+	for _, m := range c.Methods {
+		if strings.EqualFold(m, r.Method) {
+			return true
+		}
+	}
+	if ua := r.UserAgent(); ua != "" {
+		for _, s := range c.UserAgents {
+			if strings.Contains(ua, s) {
+				return true
+			}
+		}
+	}
+	return c.Skip != nil && c.Skip(r)
+}
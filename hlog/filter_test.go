@@ -0,0 +1,42 @@
+package hlog
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilter(t *testing.T) {
+	out := &bytes.Buffer{}
+	metrics := NewMetrics(MetricsConfig{Registry: prometheus.NewRegistry(), Route: RouteFromPath})
+	filter := HealthCheckFilter()
+	filter.Methods = []string{http.MethodOptions}
+	logger := NewWithConfig(Config{Writer: out, Metrics: metrics, Filter: filter})
+	h := logger.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+	}))
+
+	probe := httptest.NewRequest(http.MethodGet, "/", nil)
+	probe.Header.Set("User-Agent", "kube-probe/1.29")
+	for _, r := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/healthz", nil),
+		httptest.NewRequest(http.MethodOptions, "/users", nil),
+		probe,
+	} {
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	assert.Empty(t, out.String())
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"john"}`)))
+	assert.Contains(t, out.String(), `"requestBody":{"name":"john"}`)
+
+	w := httptest.NewRecorder()
+	metrics.ExposeHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, w.Body.String(), `http_requests_total{method="GET",route="/healthz",status="2xx"} 1`)
+}
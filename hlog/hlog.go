@@ -1,20 +1,20 @@
 package hlog
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"fmt"
 	"io"
-	"net"
 	"net/http"
 	"os"
-	"strings"
 	"time"
 
+	"github.com/JoinVerse/obs/clientip"
+	"github.com/JoinVerse/obs/logformat"
+	"github.com/JoinVerse/obs/redact"
 	"github.com/rs/xid"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/hlog"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var logger *zerolog.Logger
@@ -22,7 +22,7 @@ var logger *zerolog.Logger
 func init() {
 	zerolog.TimeFieldFormat = time.RFC3339Nano
 	host, _ := os.Hostname()
-	l := zerolog.New(os.Stdout).With().Timestamp().Str("host", host).Logger()
+	l := zerolog.New(logformat.NewWriter(logformat.FromEnv(), os.Stdout)).With().Timestamp().Str("host", host).Logger()
 	logger = &l
 }
 
@@ -33,6 +33,46 @@ func init() {
 // you may consider a sync wrapper.
 type LoggerZ struct {
 	zerolog.Logger
+	config Config
+}
+
+// Config handles LoggerZ configuration.
+type Config struct {
+	// Writer is where the logs are written. Defaults to os.Stdout.
+	Writer io.Writer
+	// Format is the format of the logs. Defaults to the OBS_LOG_FORMAT environment
+	// variable or, if it is not set, to JSON.
+	Format logformat.Format
+	// GCloudProjectID is used to add the logging.googleapis.com trace fields, so
+	// GCP Cloud Logging groups the entries of the same trace.
+	GCloudProjectID string
+	// TracerProvider is used to start a span per request. Defaults to the global OpenTelemetry provider.
+	TracerProvider trace.TracerProvider
+	// Propagator extracts the trace context from the request headers. Defaults to the
+	// global OpenTelemetry propagator or, if none is set, to W3C Trace Context.
+	Propagator propagation.TextMapPropagator
+	// Metrics, when set, records the metrics of every request.
+	Metrics *Metrics
+	// Sampler, when set, decides which access log entries are written.
+	Sampler *Sampler
+	// Filter selects the requests that are not logged.
+	Filter FilterConfig
+	// RouteExtractors return the route template of the request, logged in the route
+	// field and used by the Metrics and the Sampler. The first non-empty route is
+	// used. Defaults to ServeMuxRoute.
+	RouteExtractors []RouteExtractor
+	// Redactor, when set, masks the sensitive data of the request body, url and referer.
+	Redactor *redact.Redactor
+	// Body configures how the request body is logged.
+	Body BodyConfig
+	// Response configures the capture of the response body and headers.
+	Response ResponseConfig
+	// ClientIP resolves the remoteIp of the requests behind trusted proxies. Defaults
+	// to a resolver trusting the private networks.
+	ClientIP *clientip.Resolver
+	// Cache, when set, returns how the response was served by a cache, to fill the
+	// cache fields of the access log.
+	Cache func(r *http.Request, header http.Header) CacheStatus
 }
 
 // New creates a root logger with os.Stdout writer.
@@ -42,107 +82,84 @@ func New() LoggerZ {
 
 // NewWithWriter creates a root logger with given output writer
 func NewWithWriter(w io.Writer) LoggerZ {
+	return NewWithConfig(Config{Writer: w})
+}
+
+// NewWithConfig creates a root logger with the given configuration.
+func NewWithConfig(config Config) LoggerZ {
+	if config.Writer == nil {
+		config.Writer = os.Stdout
+	}
+	if config.Format == "" {
+		config.Format = logformat.FromEnv()
+	}
 	host, _ := os.Hostname()
-	l := zerolog.New(w).With().Timestamp().Str("host", host).Logger()
-	return LoggerZ{l}
+	l := zerolog.New(logformat.NewWriter(config.Format, config.Writer)).With().Timestamp().Str("host", host).Logger()
+	return LoggerZ{Logger: l, config: config}
 }
 
+// Handler is a middleware that starts a span and logs the end of each request,
+// along with the request body and id.
 func (l *LoggerZ) Handler(h http.Handler) http.Handler {
 	zLogger := l.Logger
 	handler := hlog.NewHandler(zLogger)
-	accessHandler := hlog.AccessHandler(
-		func(r *http.Request, status, size int, duration time.Duration) {
-			hlog.FromRequest(r).Info().
+	traceHandler := TraceHandler(l.config.TracerProvider, l.config.Propagator, l.config.GCloudProjectID)
+	responseConfig := l.config.Response
+	if responseConfig.Redactor == nil {
+		responseConfig.Redactor = l.config.Redactor
+	}
+	accessHandler := accessHandler(responseConfig,
+		func(r *http.Request, w *responseWriter, duration time.Duration) {
+			status, size := w.status, w.size
+			endSpan(r, status)
+			if l.config.Metrics != nil {
+				l.config.Metrics.observe(r, status, size, duration)
+			}
+			if l.config.Filter.skip(r) {
+				return
+			}
+			if l.config.Sampler != nil && !l.config.Sampler.keep(r, status) {
+				return
+			}
+			level, severity := severity(status)
+			e := hlog.FromRequest(r).WithLevel(level).
+				Str("severity", severity).
 				Dur("duration", duration).
-				Dict(
-					"httpRequest", zerolog.Dict().
-						Str("requestMethod", r.Method).
-						Str("requestUrl", r.URL.String()).
-						// TODO The size of the HTTP request message in bytes, including the request headers
-						// and the request body. Str("requestSize", "0").
-						Int("status", status).
-						Str("responseSize", fmt.Sprintf("%d", size)).
-						Str("userAgent", r.UserAgent()).
-						Str("remoteIp", getIPAddress(r)).
-						// TODO The IP address (IPv4 or IPv6) of the origin server that the request was sent to.
-						// Str("serverIp", "").
-						Str("referer", r.Referer()).
-						Str("latency", fmt.Sprintf("%fs", duration.Seconds())).
-						Str("protocol", r.Proto),
-				).
-				Msg("")
+				Dict("httpRequest", l.httpRequest(r, w, duration))
+			if route, ok := RouteFromContext(r.Context()); ok {
+				e.Str("route", route)
+			}
+			w.logResponse(e)
+			e.Msg("")
 		},
 	)
 
-	requestBodyHandler := RequestBodyHandler("requestBody")
+	bodyConfig := l.config.Body
+	if bodyConfig.Redactor == nil {
+		bodyConfig.Redactor = l.config.Redactor
+	}
+	if skip := bodyConfig.Skip; skip != nil {
+		bodyConfig.Skip = func(r *http.Request) bool { return skip(r) || l.config.Filter.skip(r) }
+	} else {
+		bodyConfig.Skip = l.config.Filter.skip
+	}
+	requestBodyHandler := RequestBodyHandlerWithConfig("requestBody", bodyConfig)
 	requestIDHandler := RequestIDHeaderHandler("requestId", "X-Request-Id")
+	extractors := l.config.RouteExtractors
+	if extractors == nil {
+		extractors = []RouteExtractor{ServeMuxRoute}
+	}
 	return handler(
-		accessHandler(requestBodyHandler(requestIDHandler(h))),
+		traceHandler(routeHolderHandler(accessHandler(requestBodyHandler(requestIDHandler(routeHandler(extractors)(h)))))),
 	)
 }
 
-var xForwardedFor = http.CanonicalHeaderKey("X-Forwarded-For")
-var xRealIP = http.CanonicalHeaderKey("X-Real-IP")
-
-func getIPAddress(r *http.Request) string {
-	var ip string
-	if xrip := r.Header.Get(xRealIP); xrip != "" {
-		ip = xrip
-	} else if xff := r.Header.Get(xForwardedFor); xff != "" {
-		i := strings.Index(xff, ", ")
-		if i == -1 {
-			i = len(xff)
-		}
-		ip = xff[:i]
-	} else if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
-		ip = host
-	}
-
-	return ip
-}
-
-// RequestBodyHandler adds the requested Body as a field to the context's logger
-// using fieldKey as field key.
-func RequestBodyHandler(fieldKey string) func(next http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(
-			func(w http.ResponseWriter, r *http.Request) {
-				if r.Body != nil && r.Body != http.NoBody {
-					log := zerolog.Ctx(r.Context())
-					// Read the content
-					var bodyBytes []byte
-					if r.Body != nil {
-						bodyBytes, _ = io.ReadAll(r.Body)
-					}
-					// Restore the io.ReadCloser to its original state
-					r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-					log.UpdateContext(
-						func(c zerolog.Context) zerolog.Context {
-							// Use the content
-							if isJSON(bodyBytes) {
-								return c.RawJSON(fieldKey, bodyBytes)
-							}
-							return c.Bytes(fieldKey, bodyBytes)
-						},
-					)
-				}
-				next.ServeHTTP(w, r)
-			},
-		)
-	}
-}
-
-func isJSON(s []byte) bool {
-	var js map[string]interface{}
-	return json.Unmarshal(s, &js) == nil
-}
-
 // Deprecated: Use LoggerZ object instead.
 // Logger is a middleware that logs end of each request, along with
 // some useful data about what was requested, what the response status was,
 // and how long it took to return.
 func Logger(h http.Handler) http.Handler {
-	l := &LoggerZ{*logger}
+	l := &LoggerZ{Logger: *logger}
 	return l.Handler(h)
 }
 
@@ -153,7 +170,8 @@ type idKey struct{}
 // id to the request which can be gathered using IDFromRequest(req). If the header does
 // not exist this generated id is added as a field to the logger using the passed
 // fieldKey as field name. The id is also added as a response header if the headerName
-// is not empty.
+// is not empty. The id is stored in the request context and can be gathered
+// using RequestIDFromContext(ctx).
 //
 // The generated id is a URL safe base64 encoded mongo object-id-like unique id.
 // Mongo unique id generation algorithm has been selected as a trade-off between
@@ -169,11 +187,11 @@ func RequestIDHeaderHandler(fieldKey, headerName string) func(next http.Handler)
 					id, ok := hlog.IDFromRequest(r)
 					if !ok {
 						id = xid.New()
-						ctx = context.WithValue(ctx, idKey{}, id)
-						r = r.WithContext(ctx)
 					}
 					idStr = id.String()
 				}
+				ctx = WithRequestID(ctx, idStr)
+				r = r.WithContext(ctx)
 				if fieldKey != "" {
 					log := zerolog.Ctx(ctx)
 					log.UpdateContext(
@@ -190,3 +208,24 @@ func RequestIDHeaderHandler(fieldKey, headerName string) func(next http.Handler)
 		)
 	}
 }
+
+// WithRequestID returns a copy of ctx carrying the request id, to be read with
+// RequestIDFromContext. It is used to propagate the id of requests not handled by
+// RequestIDHeaderHandler, like gRPC calls.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, idKey{}, id)
+}
+
+// RequestIDFromContext returns the request id set by RequestIDHeaderHandler.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	if id, ok := ctx.Value(idKey{}).(string); ok {
+		return id, true
+	}
+	if id, ok := hlog.IDFromCtx(ctx); ok {
+		return id.String(), true
+	}
+	return "", false
+}
@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"testing"
 
+	"github.com/JoinVerse/obs/redact"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/hlog"
 	"github.com/stretchr/testify/assert"
@@ -102,7 +103,7 @@ func TestHTTPRequestLogFormat(t *testing.T) {
 	expectedHTTPRequestLog :=
 		[]byte(`{"latency":"0.000000s", "protocol":"HTTP/1.1", "referer":"https://example.com/", 
 				"remoteIp":"188.26.219.97", "requestMethod":"GET", "requestUrl":"https://example.com/", 
-				"responseSize":"2", "status":200, "userAgent":"obs"}`)
+				"requestSize":"144", "responseSize":"2", "status":200, "userAgent":"obs"}`)
 	var expectedHTTPRequestJSON map[string]interface{}
 	err := json.Unmarshal(expectedHTTPRequestLog, &expectedHTTPRequestJSON)
 	assert.Nil(t, err)
@@ -128,6 +129,7 @@ func TestHTTPRequestLogFormat(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, recorder.Code, "Response code must be 200")
 	actualLog := struct {
+		Severity       string                 `json:"severity"`
 		HTTPRequestLog map[string]interface{} `json:"httpRequest"`
 	}{}
 	err = json.Unmarshal(out.Bytes(), &actualLog)
@@ -135,4 +137,27 @@ func TestHTTPRequestLogFormat(t *testing.T) {
 
 	actualLog.HTTPRequestLog["latency"] = "0.000000s" // Just ignore the latency value
 	assert.Equal(t, expectedHTTPRequestJSON, actualLog.HTTPRequestLog, "Unexpected HttpRequest Log Format")
+	assert.Equal(t, "INFO", actualLog.Severity)
+}
+
+func TestBodyHandlerRedaction(t *testing.T) {
+	r := &http.Request{
+		Method: http.MethodPost,
+		URL:    &url.URL{Path: "/login", RawQuery: "token=abc"},
+		Body:   io.NopCloser(bytes.NewBufferString(`{"user":"jane","password":"hunter2"}`)),
+	}
+
+	out := &bytes.Buffer{}
+	logger := NewWithConfig(Config{Writer: out, Redactor: redact.New(redact.DefaultConfig())})
+	h := logger.Handler(&httpTestHandler{})
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	actualLog := struct {
+		RequestBody map[string]interface{} `json:"requestBody"`
+		HTTPRequest map[string]interface{} `json:"httpRequest"`
+	}{}
+	err := json.Unmarshal(out.Bytes(), &actualLog)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"user": "jane", "password": redact.Mask}, actualLog.RequestBody)
+	assert.Equal(t, "/login?token=%5BREDACTED%5D", actualLog.HTTPRequest["requestUrl"])
 }
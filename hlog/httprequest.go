@@ -0,0 +1,102 @@
+package hlog
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// CacheStatus describes how a response was served by a cache. See the cache fields of
+// https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#HttpRequest
+type CacheStatus struct {
+	// Lookup is whether a cache lookup was attempted.
+	Lookup bool
+	// Hit is whether the response was served from the cache.
+	Hit bool
+	// ValidatedWithOriginServer is whether the cached response was validated with the origin server.
+	ValidatedWithOriginServer bool
+	// FillBytes is the number of bytes inserted into the cache.
+	FillBytes int64
+}
+
+// httpRequest returns the httpRequest field of the access log, in the format of
+// https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#HttpRequest
+// The int64 fields are strings and latency is a duration in seconds, following the proto3 JSON mapping.
+func (l *LoggerZ) httpRequest(r *http.Request, w *responseWriter, duration time.Duration) *zerolog.Event {
+	d := zerolog.Dict().
+		Str("requestMethod", r.Method).
+		Str("requestUrl", l.config.Redactor.URL(r.URL).String()).
+		Str("requestSize", strconv.FormatInt(requestSize(r, w.requestBody), 10)).
+		Int("status", w.status).
+		Str("responseSize", strconv.Itoa(w.size)).
+		Str("userAgent", r.UserAgent()).
+		Str("remoteIp", l.config.ClientIP.ClientIP(r))
+	if ip := serverIP(r); ip != "" {
+		d.Str("serverIp", ip)
+	}
+	d.Str("referer", l.config.Redactor.String(r.Referer())).
+		Str("latency", strconv.FormatFloat(duration.Seconds(), 'f', -1, 64)+"s").
+		Str("protocol", r.Proto)
+	if l.config.Cache != nil {
+		cache := l.config.Cache(r, w.Header())
+		d.Bool("cacheLookup", cache.Lookup).
+			Bool("cacheHit", cache.Hit).
+			Bool("cacheValidatedWithOriginServer", cache.ValidatedWithOriginServer).
+			Str("cacheFillBytes", strconv.FormatInt(cache.FillBytes, 10))
+	}
+	return d
+}
+
+// requestSize returns the size of the HTTP request message in bytes, including the
+// request line, the headers and the body.
+func requestSize(r *http.Request, body *countingBody) int64 {
+	size := int64(len(r.Method) + len(r.RequestURI) + len(r.Proto) + 4)
+	if r.RequestURI == "" && r.URL != nil {
+		size += int64(len(r.URL.RequestURI()))
+	}
+	if r.Host != "" {
+		size += int64(len("Host: \r\n") + len(r.Host))
+	}
+	for k, values := range r.Header {
+		for _, v := range values {
+			size += int64(len(k) + len(v) + 4)
+		}
+	}
+	size += 2
+	bodySize := r.ContentLength
+	if body != nil && body.n > bodySize {
+		bodySize = body.n
+	}
+	if bodySize > 0 {
+		size += bodySize
+	}
+	return size
+}
+
+// serverIP returns the IP address of the server the request was sent to.
+func serverIP(r *http.Request) string {
+	addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// severity returns the log level and the GCP Cloud Logging severity of a response status.
+func severity(status int) (zerolog.Level, string) {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return zerolog.ErrorLevel, "ERROR"
+	case status >= http.StatusBadRequest:
+		return zerolog.WarnLevel, "WARNING"
+	default:
+		return zerolog.InfoLevel, "INFO"
+	}
+}
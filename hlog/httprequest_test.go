@@ -0,0 +1,46 @@
+package hlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPRequestFields(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := NewWithConfig(Config{
+		Writer: out,
+		Cache: func(r *http.Request, header http.Header) CacheStatus {
+			return CacheStatus{Lookup: true, Hit: header.Get("Age") != ""}
+		},
+	})
+	h := logger.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Age", "10")
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("12345"))
+	r = r.WithContext(context.WithValue(r.Context(), http.LocalAddrContextKey, &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 8080}))
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	var line struct {
+		Level       string                 `json:"level"`
+		Severity    string                 `json:"severity"`
+		HTTPRequest map[string]interface{} `json:"httpRequest"`
+	}
+	err := json.Unmarshal(out.Bytes(), &line)
+	assert.Nil(t, err)
+	assert.Equal(t, "error", line.Level)
+	assert.Equal(t, "ERROR", line.Severity)
+	assert.Equal(t, "10.0.0.1", line.HTTPRequest["serverIp"])
+	assert.Equal(t, true, line.HTTPRequest["cacheLookup"])
+	assert.Equal(t, true, line.HTTPRequest["cacheHit"])
+	// request line (17) + Host header (19) + blank line (2) + body (5)
+	assert.Equal(t, "43", line.HTTPRequest["requestSize"])
+}
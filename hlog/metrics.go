@@ -0,0 +1,189 @@
+package hlog
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// MetricsConfig handles Metrics configuration.
+type MetricsConfig struct {
+	// Namespace is prepended to the metric names.
+	Namespace string
+	// DurationBuckets are the buckets of the request duration histogram, in seconds.
+	// Defaults to prometheus.DefBuckets.
+	DurationBuckets []float64
+	// SizeBuckets are the buckets of the response size histogram, in bytes.
+	// Defaults to 100B, 1KB, 10KB, 100KB, 1MB, 10MB and 100MB.
+	SizeBuckets []float64
+	// Route returns the route label of a request. It must return a bounded set of values
+	// to avoid a cardinality explosion. Defaults to the route recorded by LoggerZ.Handler
+	// or, when there is none, UnmatchedRoute. RouteFromPath can be used when every path
+	// served is known, scanners probing random paths would create a series per path.
+	Route func(r *http.Request) string
+	// RouteExtractors return the route template of the requests handled by
+	// Metrics.Handler. Defaults to ServeMuxRoute. LoggerZ.Handler records the route
+	// with the extractors of its Config instead.
+	RouteExtractors []RouteExtractor
+	// Registry is where the metrics are registered. Defaults to a new registry
+	// including the Go runtime and process metrics.
+	Registry *prometheus.Registry
+}
+
+// Metrics records the count, latency and response size of the HTTP requests by
+// method, route and status class, in Prometheus format. It also records the count and
+// latency of the outgoing requests sent by Transport, by host too.
+type Metrics struct {
+	registry   *prometheus.Registry
+	route      func(r *http.Request) string
+	extractors []RouteExtractor
+	requests   *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+	size       *prometheus.HistogramVec
+
+	clientRequests *prometheus.CounterVec
+	clientDuration *prometheus.HistogramVec
+}
+
+// NewMetrics creates the HTTP metrics and registers them in the configured registry.
+func NewMetrics(config MetricsConfig) *Metrics {
+	if config.DurationBuckets == nil {
+		config.DurationBuckets = prometheus.DefBuckets
+	}
+	if config.SizeBuckets == nil {
+		config.SizeBuckets = prometheus.ExponentialBuckets(100, 10, 7)
+	}
+	if config.Route == nil {
+		config.Route = recordedRoute
+	}
+	if config.RouteExtractors == nil {
+		config.RouteExtractors = []RouteExtractor{ServeMuxRoute}
+	}
+	if config.Registry == nil {
+		config.Registry = prometheus.NewRegistry()
+		config.Registry.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		)
+	}
+	labels := []string{"method", "route", "status"}
+	m := &Metrics{
+		registry:   config.Registry,
+		route:      config.Route,
+		extractors: config.RouteExtractors,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: config.Namespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests handled.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: config.Namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of the HTTP requests.",
+			Buckets:   config.DurationBuckets,
+		}, labels),
+		size: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: config.Namespace,
+			Name:      "http_response_size_bytes",
+			Help:      "Size of the HTTP responses.",
+			Buckets:   config.SizeBuckets,
+		}, labels),
+	}
+	clientLabels := []string{"method", "host", "route", "status"}
+	m.clientRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: config.Namespace,
+		Name:      "http_client_requests_total",
+		Help:      "Number of outgoing HTTP requests sent by Transport.",
+	}, clientLabels)
+	m.clientDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: config.Namespace,
+		Name:      "http_client_request_duration_seconds",
+		Help:      "Latency of the outgoing HTTP requests sent by Transport.",
+		Buckets:   config.DurationBuckets,
+	}, clientLabels)
+	m.registry.MustRegister(m.requests, m.duration, m.size, m.clientRequests, m.clientDuration)
+	return m
+}
+
+// Handler is a middleware that records the metrics of each request, with the route
+// returned by the RouteExtractors. There is no need to use it when the Metrics are set
+// in the LoggerZ Config.
+func (m *Metrics) Handler(h http.Handler) http.Handler {
+	observe := func(r *http.Request, w *responseWriter, duration time.Duration) {
+		m.observe(r, w.status, w.size, duration)
+	}
+	return routeHolderHandler(accessHandler(ResponseConfig{}, observe)(routeHandler(m.extractors)(h)))
+}
+
+// ExposeHandler returns the handler serving the metrics in Prometheus text format,
+// usually mounted on /metrics.
+func (m *Metrics) ExposeHandler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *Metrics) observe(r *http.Request, status, size int, duration time.Duration) {
+	labels := prometheus.Labels{
+		"method": methodLabel(r.Method),
+		"route":  m.route(r),
+		"status": statusClass(status),
+	}
+	m.requests.With(labels).Inc()
+	m.duration.With(labels).Observe(duration.Seconds())
+	m.size.With(labels).Observe(float64(size))
+}
+
+// observeClient records an outgoing request, status is 0 when it failed without response.
+func (m *Metrics) observeClient(r *http.Request, route string, status int, duration time.Duration) {
+	class := "error"
+	if status != 0 {
+		class = statusClass(status)
+	}
+	labels := prometheus.Labels{
+		"method": methodLabel(r.Method),
+		"host":   r.URL.Host,
+		"route":  route,
+		"status": class,
+	}
+	m.clientRequests.With(labels).Inc()
+	m.clientDuration.With(labels).Observe(duration.Seconds())
+}
+
+// methodLabel bounds the method label to the standard methods.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
+
+func statusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
+
+var idSegment = regexp.MustCompile(`^(\d+|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|[0-9a-fA-F]{16,}|[0-9a-v]{20})$`)
+
+// RouteFromPath returns the request path with the segments that look like ids,
+// numbers, UUIDs, hexadecimal strings or xids, replaced by ":id".
+func RouteFromPath(r *http.Request) string {
+	if r.URL == nil {
+		return ""
+	}
+	segments := strings.Split(r.URL.Path, "/")
+	for i, s := range segments {
+		if idSegment.MatchString(s) {
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,72 @@
+package hlog
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics := NewMetrics(MetricsConfig{Registry: prometheus.NewRegistry(), DurationBuckets: []float64{1}, Route: RouteFromPath})
+	logger := NewWithConfig(Config{Writer: io.Discard, Metrics: metrics})
+	h := logger.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/missing") {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	for _, path := range []string{"/users/42", "/users/43", "/users/42/missing"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	metrics.ExposeHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+	assert.Contains(t, body, `http_requests_total{method="GET",route="/users/:id",status="2xx"} 2`)
+	assert.Contains(t, body, `http_requests_total{method="GET",route="/users/:id/missing",status="4xx"} 1`)
+	assert.Contains(t, body, `http_request_duration_seconds_bucket{method="GET",route="/users/:id",status="2xx",le="1"} 2`)
+	assert.Contains(t, body, `http_response_size_bytes_sum{method="GET",route="/users/:id",status="2xx"} 4`)
+}
+
+func TestMetricsUnmatchedRoute(t *testing.T) {
+	metrics := NewMetrics(MetricsConfig{Registry: prometheus.NewRegistry()})
+	logger := NewWithConfig(Config{Writer: io.Discard, Metrics: metrics})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	h := logger.Handler(mux)
+	for _, path := range []string{"/users/42", "/wp-admin/a.php", "/x/.env"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	metrics.ExposeHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+	assert.Contains(t, body, `http_requests_total{method="GET",route="/users/{id}",status="2xx"} 1`)
+	assert.Contains(t, body, `http_requests_total{method="GET",route="unmatched",status="4xx"} 2`)
+}
+
+func TestMetricsHandler(t *testing.T) {
+	metrics := NewMetrics(MetricsConfig{Registry: prometheus.NewRegistry()})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	h := metrics.Handler(mux)
+	for _, path := range []string{"/users/42", "/users/43", "/wp-admin/a.php"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	metrics.ExposeHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+	assert.Contains(t, body, `http_requests_total{method="GET",route="/users/{id}",status="2xx"} 2`)
+	assert.Contains(t, body, `http_requests_total{method="GET",route="unmatched",status="4xx"} 1`)
+}
+
+func TestRouteFromPath(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/orgs/acme/users/4f1c2a3e-9b7d-4c1e-8f2a-1b2c3d4e5f60/posts/9m4e2mr0ui3e8a215n4g", nil)
+	assert.Equal(t, "/orgs/acme/users/:id/posts/:id", RouteFromPath(r))
+}
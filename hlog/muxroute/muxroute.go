@@ -0,0 +1,20 @@
+// Package muxroute extracts the route templates of the gorilla/mux router for hlog.
+package muxroute
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Route returns the path template of the route matched by gorilla/mux, like
+// "/users/{id}". LoggerZ.Handler must be used as a mux middleware, with Router.Use,
+// for the route to be available.
+func Route(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return ""
+}
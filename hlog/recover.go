@@ -0,0 +1,57 @@
+package hlog
+
+import (
+	"net/http"
+
+	"github.com/JoinVerse/obs/errtrack"
+	"github.com/rs/zerolog/hlog"
+)
+
+// RecovererConfig handles Recoverer configuration.
+type RecovererConfig struct {
+	// Response writes the response sent after a panic. Defaults to a 500 Internal Server Error.
+	Response http.Handler
+}
+
+// Recoverer is a middleware that recovers from panics, sends them as errors with the
+// stack trace of the panic and the request data to the tracker, logs them through the
+// context's logger and writes the configured response. The tracker can be nil. When the
+// handler has already written the headers or part of the body, the panic is only logged
+// and sent to the tracker, the response is left as is.
+//
+// Use it inside LoggerZ.Handler, so the access log records the response and the panic
+// is logged along with the request fields.
+func Recoverer(tracker *errtrack.ErrorTracker, config RecovererConfig) func(next http.Handler) http.Handler {
+	response := config.Response
+	if response == nil {
+		response = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		})
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+				defer func() {
+					v := recover()
+					if v == nil {
+						return
+					}
+					if v == http.ErrAbortHandler {
+						// Let net/http abort the response silently.
+						panic(v)
+					}
+					err := errtrack.NewPanicError(v)
+					if tracker != nil {
+						tracker.CaptureHTTPError(err, r, nil, nil)
+					}
+					hlog.FromRequest(r).Error().Err(err).Str("stack", string(err.Stack())).Msg("hlog: panic recovered")
+					if !rw.wroteHeader {
+						response.ServeHTTP(w, r)
+					}
+				}()
+				next.ServeHTTP(rw.wrap(), r)
+			},
+		)
+	}
+}
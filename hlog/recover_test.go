@@ -0,0 +1,70 @@
+package hlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/JoinVerse/obs/errtrack"
+	"github.com/JoinVerse/obs/errtrack/errtracktest"
+	"github.com/stretchr/testify/assert"
+)
+
+func panickingHandler(w http.ResponseWriter, r *http.Request) {
+	panic(errors.New("hlog: boom"))
+}
+
+func TestRecoverer(t *testing.T) {
+	rec := &errtracktest.Recorder{}
+	tracker := errtrack.New()
+	tracker.Register(rec)
+	out := &bytes.Buffer{}
+	logger := NewWithWriter(out)
+	h := logger.Handler(Recoverer(tracker, RecovererConfig{})(http.HandlerFunc(panickingHandler)))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	tracker.Close()
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	if assert.Len(t, rec.Errors(), 1) {
+		var panicErr *errtrack.PanicError
+		assert.ErrorAs(t, rec.Errors()[0], &panicErr)
+		assert.EqualError(t, errors.Unwrap(panicErr), "hlog: boom")
+		assert.Contains(t, string(panicErr.Stack()), "hlog.panickingHandler")
+	}
+
+	var line map[string]interface{}
+	err := json.NewDecoder(out).Decode(&line)
+	assert.Nil(t, err)
+	assert.Equal(t, "error", line["level"])
+	assert.Equal(t, "panic: hlog: boom", line["error"])
+}
+
+func TestRecovererAfterResponseStarted(t *testing.T) {
+	rec := &errtracktest.Recorder{}
+	tracker := errtrack.New()
+	tracker.Register(rec)
+	out := &bytes.Buffer{}
+	logger := NewWithWriter(out)
+	h := logger.Handler(Recoverer(tracker, RecovererConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("partial"))
+		panic(errors.New("hlog: boom"))
+	})))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	tracker.Close()
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "partial", w.Body.String())
+	assert.Len(t, rec.Errors(), 1)
+	var line map[string]interface{}
+	err := json.NewDecoder(out).Decode(&line)
+	assert.Nil(t, err)
+	assert.Equal(t, "panic: hlog: boom", line["error"])
+}
@@ -0,0 +1,230 @@
+package hlog
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/JoinVerse/obs/errtrack"
+	"github.com/JoinVerse/obs/redact"
+	"github.com/rs/zerolog"
+)
+
+// ResponseConfig handles the capture of the responses in the access log.
+type ResponseConfig struct {
+	// Enabled adds the response body and the selected headers to the access log.
+	Enabled bool
+	// MinStatus is the minimum status of the captured responses, e.g. 400 to
+	// capture only the errors. Defaults to capture all of them.
+	MinStatus int
+	// MaxSize is the maximum number of body bytes captured, longer bodies are truncated.
+	// Defaults to 64KB.
+	MaxSize int64
+	// Headers are the names of the response headers logged.
+	Headers []string
+	// DenyContentTypes are the media types whose body is never captured.
+	// Defaults to "application/octet-stream", "image/*", "audio/*" and "video/*".
+	DenyContentTypes []string
+	// Redactor, when set, masks the sensitive data of the body and headers before they are logged.
+	Redactor *redact.Redactor
+}
+
+func (c ResponseConfig) withDefaults() ResponseConfig {
+	if c.MaxSize <= 0 {
+		c.MaxSize = defaultMaxBodySize
+	}
+	if c.DenyContentTypes == nil {
+		c.DenyContentTypes = []string{"application/octet-stream", "image/*", "audio/*", "video/*"}
+	}
+	return c
+}
+
+// responseWriter records the status and size of the response, and captures its
+// first bytes when the response capture is enabled.
+type responseWriter struct {
+	http.ResponseWriter
+	config      ResponseConfig
+	status      int
+	size        int
+	wroteHeader bool
+	capture     bool
+	body        bytes.Buffer
+	truncated   bool
+	// requestBody counts the bytes of the request body read by the handler.
+	requestBody *countingBody
+}
+
+// writtenStatus returns the status of the response, or 0 while it is not written.
+func (w *responseWriter) writtenStatus() int {
+	if !w.wroteHeader {
+		return 0
+	}
+	return w.status
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	// Informational responses, like 103 Early Hints, are followed by the final one.
+	informational := status >= 100 && status < 200 && status != http.StatusSwitchingProtocols
+	if !w.wroteHeader && !informational {
+		w.wroteHeader = true
+		w.status = status
+		w.capture = w.config.Enabled && status >= w.config.MinStatus && w.allowed()
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.size += n
+	if w.capture && n > 0 {
+		if room := w.config.MaxSize - int64(w.body.Len()); room < int64(n) {
+			w.body.Write(p[:max(room, 0)])
+			w.truncated = true
+		} else {
+			w.body.Write(p[:n])
+		}
+	}
+	return n, err
+}
+
+// wrap returns w implementing the optional interfaces of the underlying writer in its
+// common shapes: http.Flusher, http.Hijacker and io.ReaderFrom for HTTP/1,
+// http.Flusher and http.Pusher for HTTP/2, and http.Flusher alone. Other interfaces
+// are reached with http.ResponseController through Unwrap.
+func (w *responseWriter) wrap() http.ResponseWriter {
+	_, fl := w.ResponseWriter.(http.Flusher)
+	_, hj := w.ResponseWriter.(http.Hijacker)
+	_, rf := w.ResponseWriter.(io.ReaderFrom)
+	_, ps := w.ResponseWriter.(http.Pusher)
+	switch {
+	case fl && hj && rf:
+		return http1Writer{flushWriter{w}}
+	case fl && ps:
+		return http2Writer{flushWriter{w}}
+	case fl:
+		return flushWriter{w}
+	}
+	return w
+}
+
+// Unwrap returns the underlying writer, to be used by http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// flushWriter is a responseWriter implementing http.Flusher.
+type flushWriter struct {
+	*responseWriter
+}
+
+func (w flushWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+// http1Writer is a responseWriter implementing the interfaces of the HTTP/1 writer.
+type http1Writer struct {
+	flushWriter
+}
+
+func (w http1Writer) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// ReadFrom lets http.ServeContent use the sendfile path of the underlying writer when
+// the body is not captured.
+func (w http1Writer) ReadFrom(r io.Reader) (int64, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.capture {
+		return io.Copy(w.responseWriter, r)
+	}
+	n, err := w.ResponseWriter.(io.ReaderFrom).ReadFrom(r)
+	w.size += int(n)
+	return n, err
+}
+
+// http2Writer is a responseWriter implementing the interfaces of the HTTP/2 writer.
+type http2Writer struct {
+	flushWriter
+}
+
+func (w http2Writer) Push(target string, opts *http.PushOptions) error {
+	return w.ResponseWriter.(http.Pusher).Push(target, opts)
+}
+
+func (w *responseWriter) allowed() bool {
+	mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	return !matchMediaType(mediaType, w.config.DenyContentTypes)
+}
+
+// logResponse adds the captured response body and headers to the log event.
+func (w *responseWriter) logResponse(e *zerolog.Event) {
+	if !w.config.Enabled || w.status < w.config.MinStatus {
+		return
+	}
+	if len(w.config.Headers) > 0 {
+		header := w.config.Redactor.Header(w.Header())
+		headers := zerolog.Dict()
+		for _, name := range w.config.Headers {
+			if v := header.Get(name); v != "" {
+				headers.Str(http.CanonicalHeaderKey(name), v)
+			}
+		}
+		e.Dict("responseHeaders", headers)
+	}
+	if w.body.Len() == 0 {
+		return
+	}
+	body := w.config.Redactor.Bytes(w.body.Bytes())
+	switch {
+	case w.truncated:
+		e.Str("responseBody", string(body)+truncatedMarker).Bool("responseBodyTruncated", true)
+	case isJSON(body):
+		e.RawJSON("responseBody", body)
+	default:
+		e.Bytes("responseBody", body)
+	}
+}
+
+// countingBody counts the bytes read from a request body.
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+// accessHandler calls f with the recorded response once the next handler returns.
+func accessHandler(config ResponseConfig, f func(r *http.Request, w *responseWriter, duration time.Duration)) func(next http.Handler) http.Handler {
+	config = config.withDefaults()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				start := time.Now()
+				rw := &responseWriter{ResponseWriter: w, config: config, status: http.StatusOK}
+				if r.Body != nil && r.Body != http.NoBody {
+					rw.requestBody = &countingBody{ReadCloser: r.Body}
+					r.Body = rw.requestBody
+				}
+				r = r.WithContext(errtrack.WithResponseStatus(r.Context(), rw.writtenStatus))
+				next.ServeHTTP(rw.wrap(), r)
+				f(r, rw, time.Since(start))
+			},
+		)
+	}
+}
@@ -0,0 +1,146 @@
+package hlog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/JoinVerse/obs/errtrack"
+	"github.com/stretchr/testify/assert"
+)
+
+func errorHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Error-Code", "E42")
+	w.WriteHeader(http.StatusBadRequest)
+	_, _ = w.Write([]byte(`{"error":"invalid email"}`))
+}
+
+func TestResponseCapture(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := NewWithConfig(Config{Writer: out, Response: ResponseConfig{
+		Enabled:   true,
+		MinStatus: http.StatusBadRequest,
+		Headers:   []string{"x-error-code", "Content-Type"},
+	}})
+	h := logger.Handler(http.HandlerFunc(errorHandler))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, `{"error":"invalid email"}`, w.Body.String())
+	var line map[string]interface{}
+	err := json.Unmarshal(out.Bytes(), &line)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"error": "invalid email"}, line["responseBody"])
+	assert.Equal(t, map[string]interface{}{"X-Error-Code": "E42", "Content-Type": "application/json"}, line["responseHeaders"])
+	assert.Equal(t, float64(http.StatusBadRequest), line["httpRequest"].(map[string]interface{})["status"])
+}
+
+func TestResponseStatusInContext(t *testing.T) {
+	var before, after int
+	logger := NewWithConfig(Config{Writer: io.Discard})
+	h := logger.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		before = errtrack.ResponseStatus(r)
+		w.WriteHeader(http.StatusNotFound)
+		after = errtrack.ResponseStatus(r)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, 0, before)
+	assert.Equal(t, http.StatusNotFound, after)
+}
+
+func TestResponseCaptureMinStatus(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := NewWithConfig(Config{Writer: out, Response: ResponseConfig{Enabled: true, MinStatus: http.StatusInternalServerError}})
+	h := logger.Handler(http.HandlerFunc(errorHandler))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var line map[string]interface{}
+	err := json.Unmarshal(out.Bytes(), &line)
+	assert.Nil(t, err)
+	assert.NotContains(t, line, "responseBody")
+}
+
+// http1Recorder is a ResponseRecorder implementing the interfaces of the HTTP/1 writer.
+type http1Recorder struct {
+	*httptest.ResponseRecorder
+	readFrom bool
+}
+
+func (w *http1Recorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, http.ErrNotSupported
+}
+
+func (w *http1Recorder) ReadFrom(r io.Reader) (int64, error) {
+	w.readFrom = true
+	return io.Copy(w.ResponseRecorder, r)
+}
+
+func TestResponseWriterEarlyHints(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := NewWithConfig(Config{Writer: out})
+	server := httptest.NewServer(logger.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", "</style.css>; rel=preload")
+		w.WriteHeader(http.StatusEarlyHints)
+		_, _ = w.Write([]byte("hello"))
+	})))
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	if assert.Nil(t, err) {
+		_ = resp.Body.Close()
+	}
+	server.Close()
+
+	var line map[string]interface{}
+	err = json.Unmarshal(out.Bytes(), &line)
+	assert.Nil(t, err)
+	httpRequest := line["httpRequest"].(map[string]interface{})
+	assert.Equal(t, float64(http.StatusOK), httpRequest["status"])
+	assert.Equal(t, "5", httpRequest["responseSize"])
+}
+
+func TestResponseWriterReadFrom(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := NewWithConfig(Config{Writer: out})
+	h := logger.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.(io.ReaderFrom).ReadFrom(strings.NewReader("hello"))
+	}))
+	w := &http1Recorder{ResponseRecorder: httptest.NewRecorder()}
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.True(t, w.readFrom, "ReadFrom must be delegated to the underlying writer")
+	assert.Equal(t, "hello", w.Body.String())
+	assert.Contains(t, out.String(), `"responseSize":"5"`)
+}
+
+// plainWriter implements none of the optional interfaces of http.ResponseWriter.
+type plainWriter struct {
+	http.ResponseWriter
+}
+
+func TestResponseWriterInterfaces(t *testing.T) {
+	logger := NewWithConfig(Config{Writer: io.Discard})
+	var flusher, hijacker, readerFrom, pusher bool
+	h := logger.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, flusher = w.(http.Flusher)
+		_, hijacker = w.(http.Hijacker)
+		_, readerFrom = w.(io.ReaderFrom)
+		_, pusher = w.(http.Pusher)
+	}))
+
+	h.ServeHTTP(plainWriter{httptest.NewRecorder()}, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, []bool{false, false, false, false}, []bool{flusher, hijacker, readerFrom, pusher})
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, []bool{true, false, false, false}, []bool{flusher, hijacker, readerFrom, pusher})
+
+	h.ServeHTTP(&http1Recorder{ResponseRecorder: httptest.NewRecorder()}, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, []bool{true, true, true, false}, []bool{flusher, hijacker, readerFrom, pusher})
+}
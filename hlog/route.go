@@ -0,0 +1,86 @@
+package hlog
+
+import (
+	"context"
+	"net/http"
+)
+
+// RouteExtractor returns the route template matched by a router, like "/users/{id}",
+// or an empty string when the request has not been routed by it. It is called with
+// the request passed to the router, once the router returns.
+type RouteExtractor func(r *http.Request) string
+
+type routeKey struct{}
+
+// routeHolder keeps the route of a request, shared by the middlewares through the context.
+type routeHolder struct {
+	route string
+}
+
+// SetRoute records the route template of the request, to be logged by LoggerZ.Handler.
+// It can be called by a route handler when no RouteExtractor supports the router.
+func SetRoute(r *http.Request, route string) {
+	if h, ok := r.Context().Value(routeKey{}).(*routeHolder); ok {
+		h.route = route
+	}
+}
+
+// RouteFromContext returns the route template recorded for the request.
+func RouteFromContext(ctx context.Context) (string, bool) {
+	if h, ok := ctx.Value(routeKey{}).(*routeHolder); ok && h.route != "" {
+		return h.route, true
+	}
+	return "", false
+}
+
+// UnmatchedRoute is the route label of the requests without a recorded route, e.g. the
+// requests not matched by any router pattern.
+const UnmatchedRoute = "unmatched"
+
+// recordedRoute returns the recorded route of the request or, when there is none, UnmatchedRoute.
+func recordedRoute(r *http.Request) string {
+	if route, ok := RouteFromContext(r.Context()); ok {
+		return route
+	}
+	return UnmatchedRoute
+}
+
+// routeOrPath returns the recorded route of the request or, when there is none, RouteFromPath.
+func routeOrPath(r *http.Request) string {
+	if route, ok := RouteFromContext(r.Context()); ok {
+		return route
+	}
+	return RouteFromPath(r)
+}
+
+// routeHolderHandler adds a holder to the request context where the route is recorded,
+// unless an outer handler already did.
+func routeHolderHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(routeKey{}).(*routeHolder); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), routeKey{}, &routeHolder{})))
+	})
+}
+
+// routeHandler records the route returned by the first extractor supporting the router,
+// unless it has been set with SetRoute.
+func routeHandler(extractors []RouteExtractor) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			h, ok := r.Context().Value(routeKey{}).(*routeHolder)
+			if !ok || h.route != "" {
+				return
+			}
+			for _, extract := range extractors {
+				if route := extract(r); route != "" {
+					h.route = route
+					return
+				}
+			}
+		})
+	}
+}
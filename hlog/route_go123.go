@@ -0,0 +1,10 @@
+//go:build go1.23
+
+package hlog
+
+import "net/http"
+
+// ServeMuxRoute returns the pattern matched by an http.ServeMux, like "GET /users/{id}".
+func ServeMuxRoute(r *http.Request) string {
+	return r.Pattern
+}
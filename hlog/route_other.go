@@ -0,0 +1,11 @@
+//go:build !go1.23
+
+package hlog
+
+import "net/http"
+
+// ServeMuxRoute returns the pattern matched by an http.ServeMux. The pattern is only
+// available from Go 1.23, it returns an empty string on older versions.
+func ServeMuxRoute(r *http.Request) string {
+	return ""
+}
@@ -0,0 +1,66 @@
+//go:debug httpmuxgo121=0
+
+package hlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/JoinVerse/obs/hlog/chiroute"
+	"github.com/JoinVerse/obs/hlog/muxroute"
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func loggedRoute(t *testing.T, out *bytes.Buffer) interface{} {
+	t.Helper()
+	var line map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatalf("Invalid log line %q: %v", out.String(), err)
+	}
+	out.Reset()
+	return line["route"]
+}
+
+func TestRoute(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	out := &bytes.Buffer{}
+	metrics := NewMetrics(MetricsConfig{Registry: prometheus.NewRegistry()})
+
+	serveMux := http.NewServeMux()
+	serveMux.HandleFunc("GET /users/{id}", ok)
+	serveMux.HandleFunc("/custom/", func(w http.ResponseWriter, r *http.Request) {
+		SetRoute(r, "/custom/{path...}")
+	})
+	logger := NewWithConfig(Config{Writer: out, Metrics: metrics})
+	h := logger.Handler(serveMux)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/42", nil))
+	assert.Equal(t, "GET /users/{id}", loggedRoute(t, out))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/custom/a/b", nil))
+	assert.Equal(t, "/custom/{path...}", loggedRoute(t, out))
+
+	chiLogger := NewWithConfig(Config{Writer: out, RouteExtractors: []RouteExtractor{chiroute.Route}})
+	chiRouter := chi.NewRouter()
+	chiRouter.Use(chiLogger.Handler)
+	chiRouter.Get("/orgs/{org}", ok)
+	chiRouter.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orgs/acme", nil))
+	assert.Equal(t, "/orgs/{org}", loggedRoute(t, out))
+
+	muxLogger := NewWithConfig(Config{Writer: out, RouteExtractors: []RouteExtractor{muxroute.Route}})
+	muxRouter := mux.NewRouter()
+	muxRouter.Use(muxLogger.Handler)
+	muxRouter.HandleFunc("/posts/{id}", ok)
+	muxRouter.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/posts/7", nil))
+	assert.Equal(t, "/posts/{id}", loggedRoute(t, out))
+
+	w := httptest.NewRecorder()
+	metrics.ExposeHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(w.Body)
+	assert.Contains(t, string(body), `route="GET /users/{id}"`)
+}
@@ -0,0 +1,69 @@
+package hlog
+
+import (
+	"math/rand"
+	"net/http"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SamplingConfig handles Sampler configuration.
+type SamplingConfig struct {
+	// Rate is the fraction, from 0 to 1, of the successful requests that are logged.
+	// A zero Rate logs every request.
+	Rate float64
+	// Routes overrides Rate for the given routes, a zero rate discards all their entries.
+	Routes map[string]float64
+	// KeepSampledTraces always logs the requests of sampled traces. Since traces are
+	// sampled at a ratio of 1 by default, enable it only along with a lower tracing ratio.
+	KeepSampledTraces bool
+	// Route returns the route of a request used to look up Routes. Defaults to the route
+	// recorded by LoggerZ.Handler or, when there is none, RouteFromPath.
+	Route func(r *http.Request) string
+}
+
+// Sampler decides which access log entries are written. Requests failing with a 4xx
+// or 5xx status, and requests of sampled traces with KeepSampledTraces, are always
+// logged, the rest are sampled by route.
+type Sampler struct {
+	config  SamplingConfig
+	dropped atomic.Uint64
+}
+
+// NewSampler creates a Sampler with the given configuration.
+func NewSampler(config SamplingConfig) *Sampler {
+	if config.Rate <= 0 {
+		config.Rate = 1
+	}
+	if config.Route == nil {
+		config.Route = routeOrPath
+	}
+	return &Sampler{config: config}
+}
+
+// Dropped returns the number of access log entries that have been discarded.
+func (s *Sampler) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// keep reports whether the access log entry of the request is written.
+func (s *Sampler) keep(r *http.Request, status int) bool {
+	if status >= http.StatusBadRequest {
+		return true
+	}
+	if s.config.KeepSampledTraces && trace.SpanContextFromContext(r.Context()).IsSampled() {
+		return true
+	}
+	rate := s.config.Rate
+	if len(s.config.Routes) > 0 {
+		if routeRate, ok := s.config.Routes[s.config.Route(r)]; ok {
+			rate = routeRate
+		}
+	}
+	if rate >= 1 || rand.Float64() < rate {
+		return true
+	}
+	s.dropped.Add(1)
+	return false
+}
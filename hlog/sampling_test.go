@@ -0,0 +1,49 @@
+package hlog
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSampler(t *testing.T) {
+	out := &bytes.Buffer{}
+	sampler := NewSampler(SamplingConfig{Rate: 1, Routes: map[string]float64{"/healthz": 0}, KeepSampledTraces: true})
+	logger := NewWithConfig(Config{Writer: out, Sampler: sampler})
+	h := logger.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fail") != "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+
+	for i := 0; i < 10; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz?fail=1", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users", nil))
+	// traceparent with the sampled flag.
+	r := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	assert.Equal(t, 3, strings.Count(out.String(), "\n"))
+	assert.Equal(t, uint64(10), sampler.Dropped())
+}
+
+func TestSamplerSampledTracesOptIn(t *testing.T) {
+	out := &bytes.Buffer{}
+	sampler := NewSampler(SamplingConfig{Routes: map[string]float64{"/healthz": 0}})
+	logger := NewWithConfig(Config{Writer: out, Sampler: sampler})
+	h := logger.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	r := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	assert.Empty(t, out.String())
+	assert.Equal(t, uint64(1), sampler.Dropped())
+}
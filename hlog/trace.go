@@ -0,0 +1,137 @@
+package hlog
+
+import (
+	"context"
+	"encoding/binary"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/JoinVerse/obs/hlog"
+
+// TraceFields returns the ids of the span in ctx as log fields: trace_id and span_id,
+// plus the logging.googleapis.com ones used by GCP Cloud Logging to group the
+// entries of a trace when projectID is not empty. It returns nil if ctx has no span.
+func TraceFields(ctx context.Context, projectID string) map[string]interface{} {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+	fields := map[string]interface{}{
+		"trace_id": sc.TraceID().String(),
+		"span_id":  sc.SpanID().String(),
+	}
+	if projectID != "" {
+		fields["logging.googleapis.com/trace"] = "projects/" + projectID + "/traces/" + sc.TraceID().String()
+		fields["logging.googleapis.com/spanId"] = sc.SpanID().String()
+		fields["logging.googleapis.com/trace_sampled"] = sc.IsSampled()
+	}
+	return fields
+}
+
+// TraceHandler starts a server span for each request, continuing the trace propagated
+// by the W3C traceparent header, or the X-Cloud-Trace-Context header set by GCP load
+// balancers, and adds the trace ids as fields to the context's logger.
+func TraceHandler(tp trace.TracerProvider, propagator propagation.TextMapPropagator, projectID string) func(next http.Handler) http.Handler {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	propagator = defaultPropagator(propagator)
+	tracer := tp.Tracer(tracerName)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+				if !trace.SpanContextFromContext(ctx).IsValid() {
+					if sc, ok := cloudTraceContext(r.Header.Get(xCloudTraceContext)); ok {
+						ctx = trace.ContextWithRemoteSpanContext(ctx, sc)
+					}
+				}
+				ctx, span := tracer.Start(ctx, r.Method,
+					trace.WithSpanKind(trace.SpanKindServer),
+					trace.WithAttributes(
+						semconv.HTTPRequestMethodKey.String(r.Method),
+						semconv.URLPath(r.URL.Path),
+						semconv.UserAgentOriginal(r.UserAgent()),
+					),
+				)
+				defer span.End()
+				if fields := TraceFields(ctx, projectID); fields != nil {
+					log := zerolog.Ctx(ctx)
+					log.UpdateContext(
+						func(c zerolog.Context) zerolog.Context {
+							return c.Fields(fields)
+						},
+					)
+				}
+				next.ServeHTTP(w, r.WithContext(ctx))
+			},
+		)
+	}
+}
+
+// defaultPropagator returns the given propagator or, when nil, the global OpenTelemetry
+// one or, if none is set, W3C Trace Context.
+func defaultPropagator(propagator propagation.TextMapPropagator) propagation.TextMapPropagator {
+	if propagator != nil {
+		return propagator
+	}
+	propagator = otel.GetTextMapPropagator()
+	if len(propagator.Fields()) == 0 {
+		propagator = propagation.TraceContext{}
+	}
+	return propagator
+}
+
+var xCloudTraceContext = http.CanonicalHeaderKey("X-Cloud-Trace-Context")
+
+// cloudTraceContext parses the X-Cloud-Trace-Context header: TRACE_ID/SPAN_ID;o=OPTIONS
+// where SPAN_ID is a decimal number and o=1 means that the trace is sampled.
+func cloudTraceContext(header string) (trace.SpanContext, bool) {
+	traceHex, rest, ok := strings.Cut(header, "/")
+	if !ok {
+		return trace.SpanContext{}, false
+	}
+	traceID, err := trace.TraceIDFromHex(traceHex)
+	if err != nil {
+		return trace.SpanContext{}, false
+	}
+	spanDec, options, _ := strings.Cut(rest, ";")
+	spanNum, err := strconv.ParseUint(spanDec, 10, 64)
+	if err != nil || spanNum == 0 {
+		return trace.SpanContext{}, false
+	}
+	var spanID trace.SpanID
+	binary.BigEndian.PutUint64(spanID[:], spanNum)
+	var flags trace.TraceFlags
+	if options == "o=1" {
+		flags = trace.FlagsSampled
+	}
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: flags,
+		Remote:     true,
+	})
+	return sc, sc.IsValid()
+}
+
+// endSpan records the response status in the span of the request.
+func endSpan(r *http.Request, status int) {
+	span := trace.SpanFromContext(r.Context())
+	if !span.IsRecording() {
+		return
+	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+}
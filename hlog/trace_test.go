@@ -0,0 +1,57 @@
+package hlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceHandler(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	out := &bytes.Buffer{}
+	logger := NewWithConfig(Config{Writer: out, GCloudProjectID: "my-project", TracerProvider: tp})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	h := logger.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	spans := exporter.GetSpans()
+	if !assert.Len(t, spans, 1) {
+		return
+	}
+	span := spans[0]
+	assert.Equal(t, trace.SpanKindServer, span.SpanKind)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent.SpanID().String())
+
+	var line map[string]interface{}
+	err := json.Unmarshal(out.Bytes(), &line)
+	assert.Nil(t, err)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", line["trace_id"])
+	assert.Equal(t, span.SpanContext.SpanID().String(), line["span_id"])
+	assert.Equal(t, "projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736", line["logging.googleapis.com/trace"])
+	assert.Equal(t, span.SpanContext.SpanID().String(), line["logging.googleapis.com/spanId"])
+	assert.Equal(t, true, line["logging.googleapis.com/trace_sampled"])
+}
+
+func TestCloudTraceContext(t *testing.T) {
+	sc, ok := cloudTraceContext("105445aa7843bc8bf206b12000100000/1;o=1")
+	assert.True(t, ok)
+	assert.Equal(t, "105445aa7843bc8bf206b12000100000", sc.TraceID().String())
+	assert.Equal(t, "0000000000000001", sc.SpanID().String())
+	assert.True(t, sc.IsSampled())
+
+	_, ok = cloudTraceContext("invalid")
+	assert.False(t, ok)
+}
@@ -0,0 +1,156 @@
+package hlog
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/JoinVerse/obs/errtrack"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TransportConfig handles Transport configuration.
+type TransportConfig struct {
+	// Base is the RoundTripper sending the requests. Defaults to http.DefaultTransport.
+	Base http.RoundTripper
+	// TracerProvider is used to start a client span per request. Defaults to the global
+	// OpenTelemetry provider.
+	TracerProvider trace.TracerProvider
+	// Propagator injects the trace context in the request headers. Defaults to the
+	// global OpenTelemetry propagator or, if none is set, to W3C Trace Context.
+	Propagator propagation.TextMapPropagator
+	// Metrics, when set, records the client metrics of every request.
+	Metrics *Metrics
+	// Route returns the route label of a request. It must return a bounded set of
+	// values, RouteFromPath can be used when the called paths are known. Defaults to
+	// an empty route.
+	Route func(r *http.Request) string
+	// ErrorTracker, when set, receives the transport errors and the 5xx responses.
+	ErrorTracker *errtrack.ErrorTracker
+}
+
+// Transport is an http.RoundTripper that logs the outgoing requests through the
+// logger of the request context, propagates the request id and the trace context
+// of the inbound request, and records the client metrics.
+type Transport struct {
+	config TransportConfig
+	tracer trace.Tracer
+}
+
+// NewTransport creates a Transport with the given configuration.
+func NewTransport(config TransportConfig) *Transport {
+	if config.Base == nil {
+		config.Base = http.DefaultTransport
+	}
+	if config.TracerProvider == nil {
+		config.TracerProvider = otel.GetTracerProvider()
+	}
+	config.Propagator = defaultPropagator(config.Propagator)
+	return &Transport{config: config, tracer: config.TracerProvider.Tracer(tracerName)}
+}
+
+type retryKey struct{}
+
+// WithRetry returns a copy of ctx recording that the requests sent with it are the
+// given retry attempt, starting from 1, so the Transport logs it.
+func WithRetry(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, retryKey{}, attempt)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	start := time.Now()
+	ctx, span := t.tracer.Start(r.Context(), r.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.ServerAddress(r.URL.Hostname()),
+			semconv.URLFull(r.URL.Redacted()),
+		),
+	)
+	defer span.End()
+
+	// A RoundTripper must not modify the request, the headers are set on a copy.
+	out := r.Clone(ctx)
+	t.config.Propagator.Inject(ctx, propagation.HeaderCarrier(out.Header))
+	if id, ok := RequestIDFromContext(ctx); ok && out.Header.Get("X-Request-Id") == "" {
+		out.Header.Set("X-Request-Id", id)
+	}
+
+	resp, err := t.config.Base.RoundTrip(out)
+	duration := time.Since(start)
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+
+	var route string
+	if t.config.Route != nil {
+		route = t.config.Route(r)
+	}
+	if t.config.Metrics != nil {
+		t.config.Metrics.observeClient(r, route, status, duration)
+	}
+	t.log(ctx, r, route, status, duration, err)
+	if t.config.ErrorTracker != nil && (err != nil || status >= http.StatusInternalServerError) {
+		t.capture(ctx, r, route, status, err)
+	}
+	return resp, err
+}
+
+func (t *Transport) log(ctx context.Context, r *http.Request, route string, status int, duration time.Duration, err error) {
+	level, _ := severity(status)
+	if err != nil {
+		level = zerolog.ErrorLevel
+	}
+	request := zerolog.Dict().
+		Str("method", r.Method).
+		Str("host", r.URL.Host)
+	if route != "" {
+		request.Str("route", route)
+	}
+	request.Int("status", status).
+		Str("latency", strconv.FormatFloat(duration.Seconds(), 'f', -1, 64)+"s")
+	if attempt, ok := ctx.Value(retryKey{}).(int); ok {
+		request.Int("retry", attempt)
+	}
+	zerolog.Ctx(ctx).WithLevel(level).Err(err).Dict("httpClientRequest", request).Msg("hlog: outgoing request")
+}
+
+func (t *Transport) capture(ctx context.Context, r *http.Request, route string, status int, err error) {
+	if err == nil {
+		err = fmt.Errorf("hlog: %s %s%s responded %d %s", r.Method, r.URL.Host, route, status, http.StatusText(status))
+	}
+	tags := map[string]string{
+		"http.method": r.Method,
+		"http.host":   r.URL.Host,
+	}
+	if route != "" {
+		tags["http.route"] = route
+	}
+	if status != 0 {
+		tags["http.status"] = strconv.Itoa(status)
+	}
+	if id, ok := RequestIDFromContext(ctx); ok {
+		tags["request_id"] = id
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		tags["trace_id"] = sc.TraceID().String()
+		tags["span_id"] = sc.SpanID().String()
+	}
+	t.config.ErrorTracker.CaptureError(err, tags, nil)
+}
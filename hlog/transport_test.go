@@ -0,0 +1,63 @@
+package hlog
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/JoinVerse/obs/errtrack"
+	"github.com/JoinVerse/obs/errtrack/errtracktest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestTransport(t *testing.T) {
+	var upstream http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstream = r.Header.Clone()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	rec := &errtracktest.Recorder{}
+	tracker := errtrack.New()
+	tracker.Register(rec)
+	metrics := NewMetrics(MetricsConfig{Registry: prometheus.NewRegistry()})
+	client := &http.Client{Transport: NewTransport(TransportConfig{
+		Route:          RouteFromPath,
+		TracerProvider: sdktrace.NewTracerProvider(),
+		Propagator:     propagation.TraceContext{},
+		Metrics:        metrics,
+		ErrorTracker:   tracker,
+	})}
+
+	out := &bytes.Buffer{}
+	logger := NewWithWriter(out)
+	h := logger.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequestWithContext(WithRetry(r.Context(), 2), http.MethodGet, server.URL+"/users/42", nil)
+		resp, err := client.Do(req)
+		if assert.Nil(t, err) {
+			resp.Body.Close()
+		}
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Request-Id", "req-1")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	tracker.Close()
+
+	assert.Equal(t, "req-1", upstream.Get("X-Request-Id"))
+	assert.NotEmpty(t, upstream.Get("traceparent"))
+	assert.Contains(t, out.String(), `"level":"error"`)
+	assert.Contains(t, out.String(), `"route":"/users/:id","status":503`)
+	assert.Contains(t, out.String(), `"retry":2`)
+	if assert.Len(t, rec.Errors(), 1) {
+		assert.Contains(t, rec.Errors()[0].Error(), "/users/:id responded 503 Service Unavailable")
+	}
+
+	w := httptest.NewRecorder()
+	metrics.ExposeHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, w.Body.String(), `http_client_requests_total{host="`+server.Listener.Addr().String()+`",method="GET",route="/users/:id",status="5xx"} 1`)
+}
@@ -0,0 +1,86 @@
+package obs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+)
+
+// Level defines log levels.
+type Level = zerolog.Level
+
+// Log levels, from the most to the least verbose.
+const (
+	TraceLevel = zerolog.TraceLevel
+	DebugLevel = zerolog.DebugLevel
+	InfoLevel  = zerolog.InfoLevel
+	WarnLevel  = zerolog.WarnLevel
+	ErrorLevel = zerolog.ErrorLevel
+	FatalLevel = zerolog.FatalLevel
+)
+
+// ParseLevel converts a level name (trace, debug, info, warn, error, fatal) into a Level.
+func ParseLevel(name string) (Level, error) {
+	level, err := zerolog.ParseLevel(name)
+	if err != nil || level < TraceLevel || level > FatalLevel {
+		return InfoLevel, fmt.Errorf("obs: unknown log level %q", name)
+	}
+	return level, nil
+}
+
+// atomicLevel is a Level that can be safely changed while logging.
+type atomicLevel struct {
+	v atomic.Int32
+}
+
+func newAtomicLevel(level Level) *atomicLevel {
+	l := &atomicLevel{}
+	l.set(level)
+	return l
+}
+
+func (l *atomicLevel) get() Level {
+	return Level(l.v.Load())
+}
+
+func (l *atomicLevel) set(level Level) {
+	l.v.Store(int32(level))
+}
+
+// LevelHandler returns an http.Handler to read and change the level of the logger at runtime.
+// GET responds with the current level, PUT and POST change it using the level form
+// value or a JSON body like {"level":"debug"}.
+func LevelHandler(l *Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			name := r.FormValue("level")
+			if name == "" {
+				var payload struct {
+					Level string `json:"level"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+					http.Error(w, "obs: cannot decode level", http.StatusBadRequest)
+					return
+				}
+				name = payload.Level
+			}
+			level, err := ParseLevel(name)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			l.SetLevel(level)
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"level": l.Level().String()})
+	})
+}
@@ -0,0 +1,39 @@
+//go:build unix
+
+package obs
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// HandleLevelSignals changes the level of the logger when the process receives
+// SIGUSR1, making it more verbose, or SIGUSR2, making it less verbose.
+// The returned function stops handling the signals.
+func (l *Logger) HandleLevelSignals() (stop func()) {
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				level := l.Level()
+				if sig == syscall.SIGUSR1 && level > TraceLevel {
+					level--
+				} else if sig == syscall.SIGUSR2 && level < FatalLevel {
+					level++
+				}
+				l.SetLevel(level)
+				l.zl.Log().Msgf("obs: log level changed to %s", level)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
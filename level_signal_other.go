@@ -0,0 +1,8 @@
+//go:build !unix
+
+package obs
+
+// HandleLevelSignals does nothing since SIGUSR1 and SIGUSR2 are not available on this platform.
+func (l *Logger) HandleLevelSignals() (stop func()) {
+	return func() {}
+}
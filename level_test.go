@@ -0,0 +1,59 @@
+package obs
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoggerLevel(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := NewLoggerWithWriter(out)
+	child := logger.With(String("child", "yes"))
+
+	child.Debug("hidden")
+	assert.Empty(t, out.String(), "Debug must be disabled by default")
+
+	logger.SetLevel(DebugLevel)
+	child.Debug("shown")
+	line := decodeLine(t, out)
+	assert.Equal(t, "debug", line["level"])
+	assert.Equal(t, "shown", line["message"])
+
+	logger.SetLevel(ErrorLevel)
+	child.Warn("hidden")
+	assert.Empty(t, out.String(), "Warn must be disabled at error level")
+}
+
+func TestObserverWarn(t *testing.T) {
+	out := &bytes.Buffer{}
+	observer := Observer{log: NewLoggerWithWriter(out)}
+	observer.Warn("cache: slow")
+	line := decodeLine(t, out)
+	assert.Equal(t, "warn", line["level"])
+	assert.Equal(t, "cache: slow", line["message"])
+}
+
+func TestLevelHandler(t *testing.T) {
+	logger := NewNopLogger()
+	h := LevelHandler(logger)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/level", strings.NewReader(`{"level":"debug"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"level":"debug"}`, w.Body.String())
+	assert.Equal(t, DebugLevel, logger.Level())
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/level?level=verbose", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, DebugLevel, logger.Level())
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/level", nil))
+	assert.JSONEq(t, `{"level":"debug"}`, w.Body.String())
+}
@@ -1,12 +1,53 @@
 package log
 
 import (
+	"os"
+
 	"github.com/JoinVerse/obs"
+	"github.com/JoinVerse/obs/logformat"
 )
 
-// Logger is the global logger.
+// Logger is the global logger. Its format is set by the OBS_LOG_FORMAT environment variable.
 var Logger = obs.NewLogger()
 
+// SetFormat replaces the global logger by one writing to Stderr in the given format,
+// keeping the current level.
+func SetFormat(format logformat.Format) {
+	l := obs.NewLoggerWithFormat(os.Stderr, format)
+	l.SetLevel(Logger.Level())
+	Logger = l
+}
+
+// SetLevel changes the minimum level of the messages logged by the global logger.
+func SetLevel(level obs.Level) {
+	Logger.SetLevel(level)
+}
+
+// Trace logs a trace message.
+func Trace(msg string) {
+	Logger.Trace(msg)
+}
+
+// Tracef formats and logs a trace message.
+func Tracef(format string, v ...interface{}) {
+	Logger.Tracef(format, v...)
+}
+
+// Debug logs a debug message.
+func Debug(msg string) {
+	Logger.Debug(msg)
+}
+
+// Debugf formats and logs a debug message.
+func Debugf(format string, v ...interface{}) {
+	Logger.Debugf(format, v...)
+}
+
+// DebugKV logs a debug message with the given alternated keys and values.
+func DebugKV(msg string, kv ...interface{}) {
+	Logger.DebugKV(msg, kv...)
+}
+
 func Info(msg string) {
 	Logger.Info(msg)
 }
@@ -15,10 +56,45 @@ func Infof(format string, v ...interface{}) {
 	Logger.Infof(format, v...)
 }
 
+// InfoKV logs an info message with the given alternated keys and values.
+func InfoKV(msg string, kv ...interface{}) {
+	Logger.InfoKV(msg, kv...)
+}
+
+// Warn logs a warning message.
+func Warn(msg string) {
+	Logger.Warn(msg)
+}
+
+// Warnf formats and logs a warning message.
+func Warnf(format string, v ...interface{}) {
+	Logger.Warnf(format, v...)
+}
+
+// WarnKV logs a warning message with the given alternated keys and values.
+func WarnKV(msg string, kv ...interface{}) {
+	Logger.WarnKV(msg, kv...)
+}
+
 func Error(msg string, err error) {
 	Logger.Error(msg, err)
 }
 
+// ErrorKV logs an error message with the given alternated keys and values.
+func ErrorKV(msg string, err error, kv ...interface{}) {
+	Logger.ErrorKV(msg, err, kv...)
+}
+
 func Fatal(msg string, err error) {
 	Logger.Fatal(msg, err)
 }
+
+// FatalKV logs a fatal message with the given alternated keys and values.
+func FatalKV(msg string, err error, kv ...interface{}) {
+	Logger.FatalKV(msg, err, kv...)
+}
+
+// With returns a child of the global logger which adds the given fields to every log entry.
+func With(fields ...obs.Field) *obs.Logger {
+	return Logger.With(fields...)
+}
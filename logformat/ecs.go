@@ -0,0 +1,52 @@
+package logformat
+
+import (
+	"bytes"
+
+	"github.com/rs/zerolog"
+)
+
+// ecsVersion is the version of the Elastic Common Schema used.
+const ecsVersion = "8.11.0"
+
+// ecsFields maps the fields logged by obs to the ECS fields.
+var ecsFields = map[string][2]string{
+	"host":      {"host", "hostname"},
+	"requestId": {"http", "request.id"},
+	"trace_id":  {"trace", "id"},
+	"span_id":   {"span", "id"},
+}
+
+// encodeECS renames the zerolog fields to the Elastic Common Schema ones. The
+// fields without an ECS equivalent are kept unchanged.
+func encodeECS(buf *bytes.Buffer, entry map[string]interface{}) error {
+	if t, ok := pop(entry, zerolog.TimestampFieldName); ok {
+		entry["@timestamp"] = t
+	}
+	if msg, ok := pop(entry, zerolog.MessageFieldName); ok {
+		entry["message"] = msg
+	}
+	if level, ok := pop(entry, zerolog.LevelFieldName); ok {
+		nest(entry, "log", "level", level)
+	}
+	if err, ok := pop(entry, zerolog.ErrorFieldName); ok {
+		nest(entry, "error", "message", err)
+	}
+	for field, ecs := range ecsFields {
+		if v, ok := pop(entry, field); ok {
+			nest(entry, ecs[0], ecs[1], v)
+		}
+	}
+	nest(entry, "ecs", "version", ecsVersion)
+	return encodeJSON(buf, entry)
+}
+
+// nest sets entry[object][key], creating the object if needed.
+func nest(entry map[string]interface{}, object, key string, v interface{}) {
+	m, ok := entry[object].(map[string]interface{})
+	if !ok {
+		m = map[string]interface{}{}
+		entry[object] = m
+	}
+	m[key] = v
+}
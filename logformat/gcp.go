@@ -0,0 +1,120 @@
+package logformat
+
+import (
+	"bytes"
+	"encoding/json"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// gcpSeverity maps the zerolog levels to the Cloud Logging severities.
+var gcpSeverity = map[string]string{
+	"trace": "DEBUG",
+	"debug": "DEBUG",
+	"info":  "INFO",
+	"warn":  "WARNING",
+	"error": "ERROR",
+	"fatal": "CRITICAL",
+	"panic": "ALERT",
+}
+
+// encodeGCP replaces the level by the Cloud Logging severity and adds the
+// sourceLocation of the call that logged the entry.
+func encodeGCP(buf *bytes.Buffer, entry map[string]interface{}) error {
+	level, _ := pop(entry, zerolog.LevelFieldName)
+	if _, ok := entry["severity"]; !ok {
+		severity, ok := gcpSeverity[toString(level)]
+		if !ok {
+			severity = "DEFAULT"
+		}
+		entry["severity"] = severity
+	}
+	if _, ok := entry["logging.googleapis.com/sourceLocation"]; !ok {
+		if loc := sourceLocation(); loc != nil {
+			entry["logging.googleapis.com/sourceLocation"] = loc
+		}
+	}
+	return encodeJSON(buf, entry)
+}
+
+// rewriteGCP replaces the level, written first by zerolog, by the Cloud Logging severity
+// and appends the sourceLocation, without decoding the line. It returns false for the
+// lines not starting with the level, or having a severity or sourceLocation of their own.
+func rewriteGCP(buf *bytes.Buffer, p []byte) bool {
+	prefix := `{"` + zerolog.LevelFieldName + `":"`
+	if !bytes.HasPrefix(p, []byte(prefix)) ||
+		bytes.Contains(p, []byte(`"severity":`)) ||
+		bytes.Contains(p, []byte(`"logging.googleapis.com/sourceLocation":`)) {
+		return false
+	}
+	rest := p[len(prefix):]
+	end := bytes.IndexByte(rest, '"')
+	if end < 0 {
+		return false
+	}
+	severity, ok := gcpSeverity[string(rest[:end])]
+	if !ok {
+		severity = "DEFAULT"
+	}
+	fields, ok := bytes.CutSuffix(bytes.TrimRight(rest[end+1:], "\n"), []byte("}"))
+	if !ok {
+		return false
+	}
+	buf.WriteString(`{"severity":"`)
+	buf.WriteString(severity)
+	buf.WriteByte('"')
+	buf.Write(fields)
+	if loc := sourceLocation(); loc != nil {
+		b, err := json.Marshal(loc)
+		if err != nil {
+			return false
+		}
+		buf.WriteString(`,"logging.googleapis.com/sourceLocation":`)
+		buf.Write(b)
+	}
+	buf.WriteString("}\n")
+	return true
+}
+
+// internalPackages are skipped to find the caller of the logger.
+var internalPackages = []string{
+	"github.com/rs/zerolog",
+	"github.com/JoinVerse/obs.",
+	"github.com/JoinVerse/obs/log.",
+	"github.com/JoinVerse/obs/hlog.",
+	"github.com/JoinVerse/obs/hgrpc.",
+	"github.com/JoinVerse/obs/errtrack.",
+	"github.com/JoinVerse/obs/logformat.",
+}
+
+// sourceLocation returns the first frame outside of the logging packages. Zerolog
+// writes the entries from the goroutine logging them, so it is in the current stack.
+func sourceLocation() map[string]string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !isInternal(frame.Function) {
+			return map[string]string{
+				"file":     frame.File,
+				"line":     strconv.Itoa(frame.Line),
+				"function": frame.Function,
+			}
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+func isInternal(function string) bool {
+	for _, p := range internalPackages {
+		if strings.HasPrefix(function, p) {
+			return true
+		}
+	}
+	return strings.HasPrefix(function, "runtime.")
+}
@@ -0,0 +1,79 @@
+package logformat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// encodeLogfmt writes the time, level and message followed by the rest of the fields
+// sorted by key. Nested objects are flattened with dotted keys.
+func encodeLogfmt(buf *bytes.Buffer, entry map[string]interface{}) error {
+	for _, key := range []string{zerolog.TimestampFieldName, zerolog.LevelFieldName, zerolog.MessageFieldName} {
+		if v, ok := pop(entry, key); ok {
+			if key == zerolog.MessageFieldName {
+				key = "msg"
+			}
+			writePair(buf, key, v)
+		}
+	}
+	writeFields(buf, "", entry)
+	buf.WriteByte('\n')
+	return nil
+}
+
+func writeFields(buf *bytes.Buffer, prefix string, fields map[string]interface{}) {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if m, ok := fields[k].(map[string]interface{}); ok {
+			writeFields(buf, prefix+k+".", m)
+			continue
+		}
+		writePair(buf, prefix+k, fields[k])
+	}
+}
+
+func writePair(buf *bytes.Buffer, key string, v interface{}) {
+	if buf.Len() > 0 {
+		buf.WriteByte(' ')
+	}
+	buf.WriteString(key)
+	buf.WriteByte('=')
+	buf.WriteString(logfmtValue(toString(v)))
+}
+
+// toString returns strings and numbers as they are, and the rest of values encoded as JSON.
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// logfmtValue quotes the values that are empty or contain spaces, quotes or equal signs.
+func logfmtValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n\\") {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,116 @@
+// Package logformat converts the JSON lines written by zerolog into other log formats.
+package logformat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// Format is the output format of the logs.
+type Format string
+
+// Supported formats.
+const (
+	// JSON is the zerolog JSON format.
+	JSON Format = "json"
+	// GCP is the GCP Cloud Logging structured JSON format, with severity and sourceLocation.
+	GCP Format = "gcp"
+	// ECS is the Elastic Common Schema JSON format.
+	ECS Format = "ecs"
+	// Logfmt writes key=value pairs.
+	Logfmt Format = "logfmt"
+	// Console is a colorized human-friendly format for local development.
+	Console Format = "console"
+)
+
+// EnvVar is the environment variable used to select the format.
+const EnvVar = "OBS_LOG_FORMAT"
+
+// Parse converts a format name into a Format. An empty name is JSON.
+func Parse(name string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(name))); f {
+	case "":
+		return JSON, nil
+	case JSON, GCP, ECS, Logfmt, Console:
+		return f, nil
+	}
+	return JSON, fmt.Errorf("logformat: unknown format %q", name)
+}
+
+// FromEnv returns the format set in the OBS_LOG_FORMAT environment variable,
+// or JSON if it is not set or is not valid.
+func FromEnv() Format {
+	f, _ := Parse(os.Getenv(EnvVar))
+	return f
+}
+
+// NewWriter returns a writer converting the zerolog JSON lines written to it into
+// the given format before writing them to w. Lines that are not valid JSON are
+// written unchanged.
+//
+// The GCP writer replaces the level written first by zerolog in place, keeping the
+// order of the fields. The ECS and logfmt writers, and the GCP one for the lines with
+// a severity of their own, decode every line into a map and encode it again: it costs
+// several allocations per line, see BenchmarkWriter, and the fields are written sorted
+// by key instead of in the logged order.
+func NewWriter(format Format, w io.Writer) io.Writer {
+	switch format {
+	case GCP:
+		return &writer{out: w, rewrite: rewriteGCP, encode: encodeGCP}
+	case ECS:
+		return &writer{out: w, encode: encodeECS}
+	case Logfmt:
+		return &writer{out: w, encode: encodeLogfmt}
+	case Console:
+		return zerolog.ConsoleWriter{Out: w, TimeFormat: "15:04:05.000"}
+	}
+	return w
+}
+
+// writer rewrites every line with rewrite when it can, otherwise it decodes the line
+// and writes it encoded by encode.
+type writer struct {
+	out     io.Writer
+	rewrite func(buf *bytes.Buffer, p []byte) bool
+	encode  func(buf *bytes.Buffer, entry map[string]interface{}) error
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	buf := &bytes.Buffer{}
+	if w.rewrite == nil || !w.rewrite(buf, p) {
+		var entry map[string]interface{}
+		d := json.NewDecoder(bytes.NewReader(p))
+		d.UseNumber()
+		if err := d.Decode(&entry); err != nil {
+			return w.out.Write(p)
+		}
+		buf.Reset()
+		if err := w.encode(buf, entry); err != nil {
+			return w.out.Write(p)
+		}
+	}
+	if _, err := w.out.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// encodeJSON writes the entry as a JSON line, without escaping HTML characters.
+func encodeJSON(buf *bytes.Buffer, entry map[string]interface{}) error {
+	e := json.NewEncoder(buf)
+	e.SetEscapeHTML(false)
+	return e.Encode(entry)
+}
+
+// pop removes the key from the entry and returns its value.
+func pop(entry map[string]interface{}, key string) (interface{}, bool) {
+	v, ok := entry[key]
+	delete(entry, key)
+	return v, ok
+}
@@ -0,0 +1,104 @@
+package logformat
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	for name, want := range map[string]Format{"": JSON, "GCP": GCP, " ecs ": ECS, "logfmt": Logfmt, "console": Console} {
+		f, err := Parse(name)
+		assert.Nil(t, err)
+		assert.Equal(t, want, f)
+	}
+	_, err := Parse("xml")
+	assert.NotNil(t, err)
+
+	t.Setenv(EnvVar, "logfmt")
+	assert.Equal(t, Logfmt, FromEnv())
+	t.Setenv(EnvVar, "xml")
+	assert.Equal(t, JSON, FromEnv())
+}
+
+func TestGCP(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := zerolog.New(NewWriter(GCP, out))
+	logger.Warn().Str("trace_id", "abc").Msg("slow query")
+
+	var line map[string]interface{}
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &line))
+	assert.Equal(t, "WARNING", line["severity"])
+	assert.Equal(t, "slow query", line["message"])
+	assert.Equal(t, "abc", line["trace_id"])
+	assert.NotContains(t, line, "level")
+	loc := line["logging.googleapis.com/sourceLocation"].(map[string]interface{})
+	assert.NotEmpty(t, loc["file"])
+	assert.NotEmpty(t, loc["line"])
+}
+
+func TestGCPKeepsFieldOrder(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := zerolog.New(NewWriter(GCP, out))
+	logger.Info().Str("b", "1").Str("a", "2").Msg("hello")
+	assert.Regexp(t, `^\{"severity":"INFO","b":"1","a":"2","message":"hello","logging.googleapis.com/sourceLocation":\{"file":.*\}\}\n$`, out.String())
+
+	out.Reset()
+	logger.Warn().Str("severity", "ERROR").Msg("overridden")
+	var line map[string]interface{}
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &line))
+	assert.Equal(t, "ERROR", line["severity"])
+	assert.NotContains(t, line, "level")
+}
+
+func TestECS(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := zerolog.New(NewWriter(ECS, out)).With().Timestamp().Str("host", "pod-1").Logger()
+	logger.Error().Err(errors.New("db: timeout")).Str("trace_id", "abc").Int("attempt", 2).Msg("cannot save")
+
+	var line map[string]interface{}
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &line))
+	assert.NotEmpty(t, line["@timestamp"])
+	assert.Equal(t, "cannot save", line["message"])
+	assert.Equal(t, map[string]interface{}{"level": "error"}, line["log"])
+	assert.Equal(t, map[string]interface{}{"message": "db: timeout"}, line["error"])
+	assert.Equal(t, map[string]interface{}{"hostname": "pod-1"}, line["host"])
+	assert.Equal(t, map[string]interface{}{"id": "abc"}, line["trace"])
+	assert.Equal(t, float64(2), line["attempt"])
+	assert.Equal(t, map[string]interface{}{"version": ecsVersion}, line["ecs"])
+}
+
+func TestLogfmt(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := zerolog.New(NewWriter(Logfmt, out))
+	logger.Info().
+		Str("user", "42").
+		Dict("httpRequest", zerolog.Dict().Int("status", 200).Str("userAgent", "curl/8.0 (x)")).
+		Msg("request handled")
+	assert.Equal(t, `level=info msg="request handled" httpRequest.status=200 httpRequest.userAgent="curl/8.0 (x)" user=42`+"\n", out.String())
+
+	out.Reset()
+	_, err := NewWriter(Logfmt, out).Write([]byte("not json\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, "not json\n", out.String())
+}
+
+func BenchmarkWriter(b *testing.B) {
+	for _, format := range []Format{JSON, GCP, ECS, Logfmt} {
+		b.Run(string(format), func(b *testing.B) {
+			logger := zerolog.New(NewWriter(format, io.Discard)).With().Timestamp().Str("requestId", "cn8k1mr0ui3e8a215n4g").Logger()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				logger.Info().
+					Dict("httpRequest", zerolog.Dict().Str("requestMethod", "GET").Int("status", 200).Str("userAgent", "curl/8.0")).
+					Str("trace_id", "4bf92f3577b34da6a3ce929d0e0e4736").
+					Msg("request handled")
+			}
+		})
+	}
+}
@@ -1,39 +1,185 @@
 package obs
 
 import (
+	"io"
 	"os"
 
+	"github.com/JoinVerse/obs/logformat"
 	"github.com/rs/zerolog"
 )
 
 // Logger implements interface.
 type Logger struct {
-	zl zerolog.Logger
+	zl    zerolog.Logger
+	level *atomicLevel
+	// projectID is the GCP project used to add the trace fields from a context.
+	projectID string
+	sampler   *sampler
+}
+
+// event returns a new event at the given level, or nil when the level is disabled
+// or the message is sampled out.
+func (l *Logger) event(level Level, msg string) *zerolog.Event {
+	if level < l.level.get() {
+		return nil
+	}
+	if l.sampler != nil && level < ErrorLevel && !l.sampler.allow(msg) {
+		return nil
+	}
+	switch level {
+	case TraceLevel:
+		return l.zl.Trace()
+	case DebugLevel:
+		return l.zl.Debug()
+	case WarnLevel:
+		return l.zl.Warn()
+	case ErrorLevel:
+		return l.zl.Error()
+	case FatalLevel:
+		// WithLevel does not exit, unlike Fatal.
+		return l.zl.WithLevel(zerolog.FatalLevel)
+	default:
+		return l.zl.Info()
+	}
+}
+
+// errEvent returns a new error event, or an info one when err is nil.
+func (l *Logger) errEvent(err error, msg string) *zerolog.Event {
+	if err == nil {
+		return l.event(InfoLevel, msg)
+	}
+	return l.event(ErrorLevel, msg).Err(err)
+}
+
+// Trace logs a trace message.
+func (l *Logger) Trace(msg string) {
+	l.event(TraceLevel, msg).Msg(msg)
+}
+
+// Tracef formats and logs a trace message.
+func (l *Logger) Tracef(format string, v ...interface{}) {
+	l.event(TraceLevel, format).Msgf(format, v...)
+}
+
+// Debug logs a debug message.
+func (l *Logger) Debug(msg string) {
+	l.event(DebugLevel, msg).Msg(msg)
+}
+
+// Debugf formats and logs a debug message.
+func (l *Logger) Debugf(format string, v ...interface{}) {
+	l.event(DebugLevel, format).Msgf(format, v...)
+}
+
+// DebugKV logs a debug message with the given alternated keys and values.
+// Fields can be mixed with the key/value pairs.
+func (l *Logger) DebugKV(msg string, kv ...interface{}) {
+	l.event(DebugLevel, msg).Fields(kvList(kv)).Msg(msg)
 }
 
 func (l *Logger) Info(msg string) {
-	l.zl.Info().Msg(msg)
+	l.event(InfoLevel, msg).Msg(msg)
 }
 
 func (l *Logger) Infof(format string, v ...interface{}) {
-	l.zl.Info().Msgf(format, v...)
+	l.event(InfoLevel, format).Msgf(format, v...)
+}
+
+// InfoKV logs an info message with the given alternated keys and values.
+// Fields can be mixed with the key/value pairs.
+func (l *Logger) InfoKV(msg string, kv ...interface{}) {
+	l.event(InfoLevel, msg).Fields(kvList(kv)).Msg(msg)
+}
+
+// Warn logs a warning message.
+func (l *Logger) Warn(msg string) {
+	l.event(WarnLevel, msg).Msg(msg)
+}
+
+// Warnf formats and logs a warning message.
+func (l *Logger) Warnf(format string, v ...interface{}) {
+	l.event(WarnLevel, format).Msgf(format, v...)
+}
+
+// WarnKV logs a warning message with the given alternated keys and values.
+// Fields can be mixed with the key/value pairs.
+func (l *Logger) WarnKV(msg string, kv ...interface{}) {
+	l.event(WarnLevel, msg).Fields(kvList(kv)).Msg(msg)
 }
 
 func (l *Logger) Error(msg string, err error) {
-	l.zl.Err(err).Msg(msg)
+	l.errEvent(err, msg).Msg(msg)
+}
+
+// ErrorKV logs an error message with the given alternated keys and values.
+// Fields can be mixed with the key/value pairs.
+func (l *Logger) ErrorKV(msg string, err error, kv ...interface{}) {
+	l.errEvent(err, msg).Fields(kvList(kv)).Msg(msg)
 }
 
 func (l *Logger) Fatal(msg string, err error) {
 	l.zl.Fatal().Err(err).Msg(msg)
 }
 
-// NewLogger returns a new Logger.
+// FatalKV logs a fatal message with the given alternated keys and values.
+// The os.Exit(1) function is called, which terminates the program immediately.
+func (l *Logger) FatalKV(msg string, err error, kv ...interface{}) {
+	l.zl.Fatal().Err(err).Fields(kvList(kv)).Msg(msg)
+}
+
+// With returns a child Logger which adds the given fields to every log entry.
+// The child shares the level of its parent.
+func (l *Logger) With(fields ...Field) *Logger {
+	return &Logger{zl: l.zl.With().Fields(fieldList(fields)).Logger(), level: l.level, projectID: l.projectID, sampler: l.sampler}
+}
+
+// Sample returns a child Logger which samples the entries below the error level:
+// only the first Burst entries with the same message are logged per Period, then
+// one of every Thereafter.
+func (l *Logger) Sample(config SamplingConfig) *Logger {
+	return &Logger{zl: l.zl, level: l.level, projectID: l.projectID, sampler: newSampler(config)}
+}
+
+// Dropped returns the number of entries discarded by sampling.
+func (l *Logger) Dropped() uint64 {
+	if l.sampler == nil {
+		return 0
+	}
+	return l.sampler.dropped.Load()
+}
+
+// Level returns the minimum level of the messages logged.
+func (l *Logger) Level() Level {
+	return l.level.get()
+}
+
+// SetLevel changes the minimum level of the messages logged, it is safe to be
+// called while logging. The level is also changed for the children loggers.
+func (l *Logger) SetLevel(level Level) {
+	l.level.set(level)
+}
+
+// NewLogger returns a new Logger writing to Stderr in the format set in the
+// OBS_LOG_FORMAT environment variable, JSON by default.
 func NewLogger() *Logger {
+	return NewLoggerWithFormat(os.Stderr, logformat.FromEnv())
+}
+
+// NewLoggerWithWriter returns a new Logger writing JSON to the given writer.
+func NewLoggerWithWriter(w io.Writer) *Logger {
+	return NewLoggerWithFormat(w, logformat.JSON)
+}
+
+// NewLoggerWithFormat returns a new Logger writing to the given writer in the given format.
+func NewLoggerWithFormat(w io.Writer, format logformat.Format) *Logger {
 	host, _ := os.Hostname()
-	return &Logger{zerolog.New(os.Stderr).With().Timestamp().Str("host", host).Logger()}
+	return &Logger{
+		zl:    zerolog.New(logformat.NewWriter(format, w)).With().Timestamp().Str("host", host).Logger(),
+		level: newAtomicLevel(InfoLevel),
+	}
 }
 
 // NewNopLogger returns a disabled Logger for which all operation are no-op.
 func NewNopLogger() *Logger {
-	return &Logger{zerolog.Nop()}
+	return &Logger{zl: zerolog.Nop(), level: newAtomicLevel(InfoLevel)}
 }
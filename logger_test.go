@@ -0,0 +1,62 @@
+package obs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/JoinVerse/obs/logformat"
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeLine(t *testing.T, out *bytes.Buffer) map[string]interface{} {
+	t.Helper()
+	var line map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatalf("Invalid log line %q: %v", out.String(), err)
+	}
+	out.Reset()
+	return line
+}
+
+func TestLoggerKV(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := NewLoggerWithWriter(out).With(String("service", "api"))
+
+	logger.InfoKV("request", "user", "42", Int("attempt", 2), Duration("elapsed", time.Second))
+	line := decodeLine(t, out)
+	assert.Equal(t, "api", line["service"])
+	assert.Equal(t, "42", line["user"])
+	assert.Equal(t, float64(2), line["attempt"])
+	assert.Equal(t, float64(1000), line["elapsed"])
+	assert.Equal(t, "request", line["message"])
+
+	logger.ErrorKV("cannot save", errors.New("db: timeout"), Bool("retry", true))
+	line = decodeLine(t, out)
+	assert.Equal(t, "api", line["service"])
+	assert.Equal(t, "db: timeout", line["error"])
+	assert.Equal(t, true, line["retry"])
+}
+
+func TestKVMap(t *testing.T) {
+	assert.Equal(t,
+		map[string]interface{}{"user": "42", "attempt": 2},
+		kvMap([]interface{}{"user", "42", Int("attempt", 2), "dangling"}),
+	)
+	assert.Nil(t, kvMap(nil))
+}
+
+func TestLoggerFormat(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := NewLoggerWithFormat(out, logformat.GCP)
+
+	logger.ErrorKV("cannot save", errors.New("db: timeout"), "user", "42")
+	line := decodeLine(t, out)
+	assert.Equal(t, "ERROR", line["severity"])
+	assert.Equal(t, "cannot save", line["message"])
+	assert.Equal(t, "db: timeout", line["error"])
+	assert.Equal(t, "42", line["user"])
+	assert.NotContains(t, line, "level")
+}
@@ -1,10 +1,16 @@
 package obs
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"os"
 
 	"cloud.google.com/go/profiler"
 	"github.com/JoinVerse/obs/errtrack"
+	"github.com/JoinVerse/obs/logformat"
+	"github.com/rs/zerolog"
 )
 
 // Config ...
@@ -13,43 +19,196 @@ type Config struct {
 	//When true, GCP integration is disabled.
 	NOGCloudEnabled bool
 	SentryConfig    errtrack.SentryConfig
+	// ErrTrackConfig configures how errors are queued before being sent to the trackers.
+	ErrTrackConfig errtrack.Config
+	// LogLevel is the minimum level of the logged messages: trace, debug, info, warn,
+	// error or fatal. Defaults to info.
+	LogLevel string
+	// LogFormat is the format of the logs: json, gcp, ecs, logfmt or console. Defaults
+	// to the OBS_LOG_FORMAT environment variable or, if it is not set, to json.
+	LogFormat string
+	// LogLevelSignals enables changing the log level at runtime with SIGUSR1, to make
+	// it more verbose, and SIGUSR2, to make it less verbose.
+	LogLevelSignals bool
+	// LogSampling, when set, samples the repeated log entries below the error level.
+	LogSampling SamplingConfig
+	// TracingConfig enables OpenTelemetry tracing. The service name and version of
+	// GCloudConfig are used to identify the spans.
+	TracingConfig TracingConfig
+	// Policies sets whether NewE fails when a backend cannot be initialized. Backends
+	// are Required by default.
+	Policies map[Backend]Policy
 }
 
 // Observer provides observer object
 type Observer struct {
 	log      *Logger
 	errTrack *errtrack.ErrorTracker
+	stop     func()
+	status   []BackendStatus
 }
 
-// New returns a new observer.
+// New returns a new observer. Backends that cannot be initialized are logged and
+// skipped, see Status. Use NewE to get the initialization errors.
 func New(config Config) Observer {
+	o, _ := newObserver(config, false)
+	return o
+}
+
+// NewE returns a new observer, or the joined initialization errors of the invalid
+// log level or format and of the backends with the Required policy. Backends are
+// Required unless set as BestEffort in Config.Policies.
+func NewE(config Config) (*Observer, error) {
+	o, err := newObserver(config, true)
+	if err != nil {
+		o.Close()
+		return nil, err
+	}
+	return &o, nil
+}
+
+// newObserver initializes the observer and its backends. When strict is true, the
+// errors of the configuration and of the required backends are returned.
+func newObserver(config Config, strict bool) (Observer, error) {
+	var errs []error
+	var status []BackendStatus
 	log := NewLogger()
-	errTrack := errtrack.New()
-	if err := errTrack.InitSentry(config.SentryConfig); err != nil {
-		log.Error("obs: cannot init Sentry", err)
+	if config.LogFormat != "" {
+		format, err := logformat.Parse(config.LogFormat)
+		if err != nil {
+			log.Error("obs: cannot set log format", err)
+			errs = append(errs, err)
+		} else {
+			log = NewLoggerWithFormat(os.Stderr, format)
+		}
+	}
+	log.projectID = config.GCloudConfig.GCloudProjectID
+	if config.LogLevel != "" {
+		level, err := ParseLevel(config.LogLevel)
+		if err != nil {
+			log.Error("obs: cannot set log level", err)
+			errs = append(errs, err)
+		}
+		log.SetLevel(level)
+	}
+	if config.LogSampling.enabled() {
+		log = log.Sample(config.LogSampling)
+	}
+	// backend records the status of a backend and logs its initialization error.
+	backend := func(b Backend, enabled bool, err error) {
+		status = append(status, BackendStatus{Backend: b, Enabled: enabled, Err: err})
+		if err == nil {
+			return
+		}
+		log.Error(fmt.Sprintf("obs: cannot init %s", b), err)
+		if config.Policies[b] == Required {
+			errs = append(errs, err)
+		}
 	}
 
-	if !config.NOGCloudEnabled {
-		if err := errTrack.InitGoogleCloudErrorReporting(config.GCloudConfig); err != nil {
-			log.Error("obs: cannot init GoogleCloudErrorReporting", err)
+	var closers []func()
+	if config.LogLevelSignals {
+		closers = append(closers, log.HandleLevelSignals())
+	}
+	var err error
+	if config.TracingConfig.enabled() {
+		var shutdown func()
+		shutdown, err = initTracing(config.TracingConfig, config.GCloudConfig.ServiceName, config.GCloudConfig.ServiceVersion)
+		if err == nil {
+			closers = append(closers, shutdown)
+		}
+	}
+	backend(BackendTracing, config.TracingConfig.enabled(), err)
+	stop := func() {
+		for _, c := range closers {
+			c()
 		}
-		if err := profiler.Start(profiler.Config{
+	}
+	errTrack := errtrack.NewWithConfig(config.ErrTrackConfig)
+	backend(BackendSentry, config.SentryConfig.SentryDSN != "", errTrack.InitSentry(config.SentryConfig))
+
+	err = nil
+	if !config.NOGCloudEnabled {
+		err = errTrack.InitGoogleCloudErrorReporting(config.GCloudConfig)
+	}
+	backend(BackendErrorReporting, !config.NOGCloudEnabled, err)
+
+	err = nil
+	if !config.NOGCloudEnabled {
+		if err = profiler.Start(profiler.Config{
 			Service:        config.GCloudConfig.ServiceName,
 			ServiceVersion: config.GCloudConfig.ServiceVersion,
 		}); err != nil {
-			log.Error("obs: cannot start GoogleCloudProfiler", err)
 			errTrack.CaptureError(err, nil, nil)
 		}
 	}
-	return Observer{log: log, errTrack: errTrack}
+	backend(BackendProfiler, !config.NOGCloudEnabled, err)
+
+	o := Observer{log: log, errTrack: errTrack, stop: stop, status: status}
+	if !strict {
+		return o, nil
+	}
+	return o, errors.Join(errs...)
 }
 
-// Close calls Flush, then closes any resources held by the client.
+// Status returns the state of each backend.
+func (o *Observer) Status() []BackendStatus {
+	return append([]BackendStatus(nil), o.status...)
+}
+
+// Close waits for the queued errors to be sent, then closes any resources held by the client.
 // Close should be called when the client is no longer needed.
 func (o *Observer) Close() {
+	o.stop()
 	o.errTrack.Close()
 }
 
+// ErrorTracker returns the tracker errors are sent to, e.g. to be used by hlog.Recoverer.
+func (o *Observer) ErrorTracker() *errtrack.ErrorTracker {
+	return o.errTrack
+}
+
+// Level returns the minimum level of the logged messages.
+func (o *Observer) Level() Level {
+	return o.log.Level()
+}
+
+// SetLevel changes the minimum level of the logged messages at runtime.
+func (o *Observer) SetLevel(level Level) {
+	o.log.SetLevel(level)
+}
+
+// LevelHandler returns an http.Handler to read and change the log level at runtime.
+// See LevelHandler.
+func (o *Observer) LevelHandler() http.Handler {
+	return LevelHandler(o.log)
+}
+
+// Trace logs a trace message to Stderr.
+func (o *Observer) Trace(msg string) {
+	o.log.Trace(msg)
+}
+
+// Tracef formats and logs a trace message to Stderr.
+func (o *Observer) Tracef(format string, v ...interface{}) {
+	o.log.Tracef(format, v...)
+}
+
+// Debug logs a debug message to Stderr.
+func (o *Observer) Debug(msg string) {
+	o.log.Debug(msg)
+}
+
+// Debugf formats and logs a debug message to Stderr.
+func (o *Observer) Debugf(format string, v ...interface{}) {
+	o.log.Debugf(format, v...)
+}
+
+// DebugKV logs a debug message with the given alternated keys and values to Stderr.
+func (o *Observer) DebugKV(msg string, kv ...interface{}) {
+	o.log.DebugKV(msg, kv...)
+}
+
 // Info logs an info message to Stderr.
 func (o *Observer) Info(msg string) {
 	o.log.Info(msg)
@@ -60,6 +219,37 @@ func (o *Observer) Infof(format string, v ...interface{}) {
 	o.log.Infof(format, v...)
 }
 
+// InfoKV logs an info message with the given alternated keys and values to Stderr.
+func (o *Observer) InfoKV(msg string, kv ...interface{}) {
+	o.log.InfoKV(msg, kv...)
+}
+
+// Warn logs a warning message to Stderr.
+func (o *Observer) Warn(msg string) {
+	o.log.Warn(msg)
+}
+
+// WarnErr logs a warning message to Stderr and send the error at the warning level to configured trackers.
+func (o *Observer) WarnErr(msg string, err error) {
+	o.CaptureLevel(WarnLevel, msg, nil, nil, err)
+}
+
+// Warnf formats and logs a warning message to Stderr.
+func (o *Observer) Warnf(format string, v ...interface{}) {
+	o.log.Warnf(format, v...)
+}
+
+// WarnKV logs a warning message with the given alternated keys and values to Stderr.
+func (o *Observer) WarnKV(msg string, kv ...interface{}) {
+	o.log.WarnKV(msg, kv...)
+}
+
+// With returns a child Observer which adds the given fields to every log entry.
+// Closing the child closes the parent.
+func (o *Observer) With(fields ...Field) Observer {
+	return Observer{log: o.log.With(fields...), errTrack: o.errTrack, stop: o.stop, status: o.status}
+}
+
 // Error logs an error message to Stderr and send the error to configured trackers.
 func (o *Observer) Error(msg string, err error) {
 	o.ErrorTags(msg, nil, err)
@@ -68,13 +258,70 @@ func (o *Observer) Error(msg string, err error) {
 // ErrorTags logs an error message to Stderr and send the error among the tags, to configured trackers.
 func (o *Observer) ErrorTags(msg string, tags map[string]string, err error) {
 	o.errTrack.CaptureError(err, tags, nil)
-	o.log.Error(msg, err)
+	o.errorEvent(o.log, err, nil, msg).Msg(msg)
 }
 
 // ErrorTagsAndContext logs an error message to Stderr and send the error among the tags and context, to configured trackers.
 func (o *Observer) ErrorTagsAndContext(msg string, tags map[string]string, context map[string]interface{}, err error) {
 	o.errTrack.CaptureError(err, tags, context)
-	o.log.Error(msg, err)
+	o.errorEvent(o.log, err, nil, msg).Msg(msg)
+}
+
+// CaptureLevel logs a message at the given level to Stderr and send the error among the tags and
+// context, at the same level, to configured trackers. Unlike Fatal, the fatal level does not exit.
+func (o *Observer) CaptureLevel(level Level, msg string, tags map[string]string, context map[string]interface{}, err error) {
+	o.errTrack.CaptureErrorLevel(trackerLevel(level), err, tags, context)
+	o.levelEvent(o.log, level, err, nil, msg).Msg(msg)
+}
+
+// ErrorKV logs an error message with the given alternated keys and values to Stderr,
+// and send the error among the key/value pairs as context, to configured trackers.
+func (o *Observer) ErrorKV(msg string, err error, kv ...interface{}) {
+	o.errTrack.CaptureError(err, nil, kvMap(kv))
+	o.errorEvent(o.log, err, nil, msg).Fields(kvList(kv)).Msg(msg)
+}
+
+// DebugCtx logs a debug message through the request-scoped logger of ctx.
+func (o *Observer) DebugCtx(ctx context.Context, msg string) {
+	o.log.FromContext(ctx).Debug(msg)
+}
+
+// InfoCtx logs an info message through the request-scoped logger of ctx.
+func (o *Observer) InfoCtx(ctx context.Context, msg string) {
+	o.log.FromContext(ctx).Info(msg)
+}
+
+// InfofCtx formats and logs an info message through the request-scoped logger of ctx.
+func (o *Observer) InfofCtx(ctx context.Context, format string, v ...interface{}) {
+	o.log.FromContext(ctx).Infof(format, v...)
+}
+
+// ErrorCtx logs an error message through the request-scoped logger of ctx and send the error
+// among the request id, the user and the tags of ctx, to configured trackers.
+func (o *Observer) ErrorCtx(ctx context.Context, msg string, err error) {
+	o.ErrorTagsAndContextCtx(ctx, msg, nil, nil, err)
+}
+
+// ErrorTagsCtx logs an error message through the request-scoped logger of ctx and send the error
+// among the given tags merged with the request id, the user and the tags of ctx, to configured trackers.
+func (o *Observer) ErrorTagsCtx(ctx context.Context, msg string, tags map[string]string, err error) {
+	o.ErrorTagsAndContextCtx(ctx, msg, tags, nil, err)
+}
+
+// ErrorTagsAndContextCtx logs an error message through the request-scoped logger of ctx and send the
+// error among the given tags merged with the request id, the user and the tags of ctx, and the context,
+// to configured trackers.
+func (o *Observer) ErrorTagsAndContextCtx(ctx context.Context, msg string, tags map[string]string, context map[string]interface{}, err error) {
+	o.errTrack.CaptureError(err, contextTags(ctx, tags), context)
+	o.errorEvent(o.log.FromContext(ctx), err, nil, msg).Msg(msg)
+}
+
+// CaptureLevelCtx logs a message at the given level through the request-scoped logger of ctx and
+// send the error among the given tags merged with the request id, the user and the tags of ctx, and
+// the context, at the same level, to configured trackers. Unlike Fatal, the fatal level does not exit.
+func (o *Observer) CaptureLevelCtx(ctx context.Context, level Level, msg string, tags map[string]string, context map[string]interface{}, err error) {
+	o.errTrack.CaptureErrorLevel(trackerLevel(level), err, contextTags(ctx, tags), context)
+	o.levelEvent(o.log.FromContext(ctx), level, err, nil, msg).Msg(msg)
 }
 
 // HTTPError logs an error message to Stderr and send the error to configured trackers.
@@ -82,21 +329,94 @@ func (o *Observer) HTTPError(r *http.Request, err error) {
 	o.HTTPErrorTags(r, nil, err)
 }
 
-// HTTPErrorTags logs an error message to Stderr and send the error among the tags, to configured trackers.
+// HTTPErrorTags logs an error message through the request-scoped logger and send the error among
+// the tags merged with the request id, the user and the tags of the request context, to configured trackers.
 func (o *Observer) HTTPErrorTags(r *http.Request, tags map[string]string, err error) {
-	o.errTrack.CaptureHTTPError(err, r, tags, nil)
-	o.log.Error("", err)
+	o.HTTPErrorTagsAndContext(r, tags, nil, err)
 }
 
-// HTTPErrorTagsAndContext logs an error message to Stderr and send the error among the tags, to configured trackers.
+// HTTPErrorTagsAndContext logs an error message through the request-scoped logger and send the error
+// among the tags merged with the request id, the user and the tags of the request context, to configured trackers.
 func (o *Observer) HTTPErrorTagsAndContext(r *http.Request, tags map[string]string, context map[string]interface{}, err error) {
+	logger := o.log
+	if r != nil {
+		tags = contextTags(r.Context(), tags)
+		logger = o.log.FromContext(r.Context())
+	}
 	o.errTrack.CaptureHTTPError(err, r, tags, context)
-	o.log.Error("", err)
+	o.errorEvent(logger, err, r, "").Msg("")
+}
+
+// HTTPErrorLevel logs a message at the given level through the request-scoped logger and send the
+// error among the tags merged with the request id, the user and the tags of the request context, and
+// the context, at the same level, to configured trackers. Unlike Fatal, the fatal level does not exit.
+func (o *Observer) HTTPErrorLevel(r *http.Request, level Level, tags map[string]string, context map[string]interface{}, err error) {
+	logger := o.log
+	if r != nil {
+		tags = contextTags(r.Context(), tags)
+		logger = o.log.FromContext(r.Context())
+	}
+	o.errTrack.CaptureHTTPErrorLevel(trackerLevel(level), err, r, tags, context)
+	o.levelEvent(logger, level, err, r, "").Msg("")
+}
+
+// errorEvent returns the log event of err, at the warning level when the classifier
+// of the tracker downgrades it.
+func (o *Observer) errorEvent(l *Logger, err error, r *http.Request, msg string) *zerolog.Event {
+	if err == nil {
+		return l.errEvent(err, msg)
+	}
+	return o.levelEvent(l, ErrorLevel, err, r, msg)
+}
+
+// levelEvent returns the log event of err at the given level, or at the warning level
+// when the classifier of the tracker downgrades it.
+func (o *Observer) levelEvent(l *Logger, level Level, err error, r *http.Request, msg string) *zerolog.Event {
+	if level > WarnLevel && err != nil && o.errTrack.Classify(err, r) == errtrack.Warn {
+		level = WarnLevel
+	}
+	return l.event(level, msg).Err(err)
+}
+
+// trackerLevel returns the errtrack.Level of a log level.
+func trackerLevel(level Level) errtrack.Level {
+	switch level {
+	case TraceLevel, DebugLevel:
+		return errtrack.LevelDebug
+	case InfoLevel:
+		return errtrack.LevelInfo
+	case WarnLevel:
+		return errtrack.LevelWarning
+	case FatalLevel:
+		return errtrack.LevelFatal
+	}
+	return errtrack.LevelError
+}
+
+// Go runs f in a new goroutine. If f panics, the panic is recovered, logged to Stderr
+// and sent as an error with the stack trace of the panic to configured trackers.
+func (o *Observer) Go(f func()) {
+	go func() {
+		defer o.recoverPanic()
+		f()
+	}()
+}
+
+func (o *Observer) recoverPanic() {
+	v := recover()
+	if v == nil {
+		return
+	}
+	err := errtrack.NewPanicError(v)
+	o.errTrack.CaptureError(err, nil, nil)
+	o.log.ErrorKV("obs: panic recovered", err, "stack", string(err.Stack()))
 }
 
 // Fatal logs a fatal message to Stderr and send the error to configured trackers.
-// The os.Exit(1) function is called, which terminates the program immediately.
+// The trackers are closed, waiting up to their CloseTimeout for the queued errors to
+// be sent, then the os.Exit(1) function is called, which terminates the program.
 func (o *Observer) Fatal(msg string, err error) {
-	o.errTrack.CaptureError(err, nil, nil)
+	o.errTrack.CaptureErrorLevel(errtrack.LevelFatal, err, nil, nil)
+	o.errTrack.Close()
 	o.log.Fatal(msg, err)
 }
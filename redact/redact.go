@@ -0,0 +1,268 @@
+// Package redact masks sensitive data, like passwords, tokens or card numbers,
+// before it is written to the logs or sent to the error trackers.
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Mask replaces the redacted values.
+const Mask = "[REDACTED]"
+
+// Common patterns of sensitive data.
+var (
+	// Email matches email addresses.
+	Email = regexp.MustCompile(`[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}`)
+	// CardNumber matches payment card numbers (PAN), with or without separators. The
+	// Redactor only masks the matches passing the Luhn check, so timestamps, ids or
+	// phone numbers are kept.
+	CardNumber = regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`)
+	// BearerToken matches the bearer tokens of an Authorization header.
+	BearerToken = regexp.MustCompile(`(?i)bearer\s+[a-zA-Z0-9\-._~+/]+=*`)
+)
+
+// Config handles the Redactor configuration.
+type Config struct {
+	// JSONPaths are the dot separated paths of the JSON fields whose values are masked.
+	// A path of a single key, like "password", matches the key at any depth. Longer
+	// paths, like "user.card.number", are matched from the root and "*" matches any
+	// key or array index.
+	JSONPaths []string
+	// Headers are the names of the headers whose values are masked.
+	Headers []string
+	// Patterns are the regular expressions whose matches are masked in any text.
+	Patterns []*regexp.Regexp
+}
+
+// DefaultConfig returns a Config masking common credential fields and headers,
+// emails, card numbers and bearer tokens.
+func DefaultConfig() Config {
+	return Config{
+		JSONPaths: []string{"password", "passwd", "secret", "token", "access_token", "refresh_token", "api_key", "apiKey"},
+		Headers:   []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"},
+		Patterns:  []*regexp.Regexp{Email, CardNumber, BearerToken},
+	}
+}
+
+// Redactor masks sensitive data. A nil Redactor returns the data unchanged.
+type Redactor struct {
+	keys     map[string]bool
+	paths    [][]string
+	headers  map[string]bool
+	patterns []*regexp.Regexp
+	// jsonKeys and formKeys match the single key paths in text that cannot be decoded,
+	// like truncated JSON documents or form encoded bodies.
+	jsonKeys *regexp.Regexp
+	formKeys *regexp.Regexp
+}
+
+// New returns a Redactor with the given configuration.
+func New(config Config) *Redactor {
+	r := &Redactor{keys: make(map[string]bool), headers: make(map[string]bool), patterns: config.Patterns}
+	for _, p := range config.JSONPaths {
+		segments := strings.Split(p, ".")
+		if len(segments) == 1 {
+			r.keys[strings.ToLower(p)] = true
+			continue
+		}
+		r.paths = append(r.paths, segments)
+	}
+	for _, h := range config.Headers {
+		r.headers[http.CanonicalHeaderKey(h)] = true
+	}
+	if len(r.keys) > 0 {
+		keys := make([]string, 0, len(r.keys))
+		for k := range r.keys {
+			keys = append(keys, regexp.QuoteMeta(k))
+		}
+		sort.Strings(keys)
+		names := strings.Join(keys, "|")
+		r.jsonKeys = regexp.MustCompile(`(?i)("(?:` + names + `)"\s*:\s*)("(?:[^"\\]|\\.)*"?|[^,}\]\s]*)`)
+		r.formKeys = regexp.MustCompile(`(?i)((?:^|[&?])(?:` + names + `)=)[^&]*`)
+	}
+	return r
+}
+
+// String masks the matches of the patterns in s.
+func (r *Redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, p := range r.patterns {
+		if p == CardNumber {
+			s = p.ReplaceAllStringFunc(s, maskCardNumber)
+			continue
+		}
+		s = p.ReplaceAllString(s, Mask)
+	}
+	return s
+}
+
+// maskCardNumber masks the card number when it passes the Luhn check.
+func maskCardNumber(s string) string {
+	if luhn(s) {
+		return Mask
+	}
+	return s
+}
+
+// luhn reports whether the digits of s pass the Luhn checksum of the card numbers.
+func luhn(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// Bytes masks the JSON paths and the matches of the patterns in b. When b is not a
+// JSON document only the patterns are applied.
+func (r *Redactor) Bytes(b []byte) []byte {
+	if r == nil || len(b) == 0 {
+		return b
+	}
+	var doc interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&doc); err != nil || d.More() {
+		return []byte(r.text(string(b)))
+	}
+	buf := &bytes.Buffer{}
+	e := json.NewEncoder(buf)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(r.value(doc, nil)); err != nil {
+		return []byte(r.text(string(b)))
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+// text masks the single key paths, as JSON or form fields, and the matches of the patterns in s.
+func (r *Redactor) text(s string) string {
+	if r.jsonKeys != nil {
+		s = r.jsonKeys.ReplaceAllString(s, `${1}"`+Mask+`"`)
+		s = r.formKeys.ReplaceAllString(s, `${1}`+Mask)
+	}
+	return r.String(s)
+}
+
+// Header returns a copy of h with the configured headers and the matches of the patterns masked.
+func (r *Redactor) Header(h http.Header) http.Header {
+	if r == nil || h == nil {
+		return h
+	}
+	redacted := make(http.Header, len(h))
+	for k, values := range h {
+		masked := make([]string, len(values))
+		for i, v := range values {
+			if r.headers[http.CanonicalHeaderKey(k)] {
+				masked[i] = Mask
+			} else {
+				masked[i] = r.String(v)
+			}
+		}
+		redacted[k] = masked
+	}
+	return redacted
+}
+
+// URL returns a copy of u with the query parameters named like a JSON key and the
+// matches of the patterns masked.
+func (r *Redactor) URL(u *url.URL) *url.URL {
+	if r == nil || u == nil {
+		return u
+	}
+	redacted := *u
+	if u.User != nil {
+		redacted.User = url.User(u.User.Username())
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for k, values := range query {
+			for i, v := range values {
+				if r.keys[strings.ToLower(k)] {
+					values[i] = Mask
+				} else {
+					values[i] = r.String(v)
+				}
+			}
+		}
+		redacted.RawQuery = query.Encode()
+	}
+	return &redacted
+}
+
+// Map returns a copy of m with the JSON paths and the matches of the patterns masked.
+func (r *Redactor) Map(m map[string]interface{}) map[string]interface{} {
+	if r == nil || m == nil {
+		return m
+	}
+	redacted, _ := r.value(m, nil).(map[string]interface{})
+	return redacted
+}
+
+// value returns a copy of v, found at path, with the sensitive data masked.
+func (r *Redactor) value(v interface{}, path []string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for k, child := range v {
+			childPath := append(path[:len(path):len(path)], k)
+			if r.matches(childPath) {
+				redacted[k] = Mask
+				continue
+			}
+			redacted[k] = r.value(child, childPath)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, child := range v {
+			redacted[i] = r.value(child, append(path[:len(path):len(path)], "*"))
+		}
+		return redacted
+	case string:
+		return r.String(v)
+	default:
+		return v
+	}
+}
+
+// matches reports whether the field at path must be masked.
+func (r *Redactor) matches(path []string) bool {
+	if r.keys[strings.ToLower(path[len(path)-1])] {
+		return true
+	}
+	for _, p := range r.paths {
+		if len(p) != len(path) {
+			continue
+		}
+		match := true
+		for i, segment := range p {
+			if segment != "*" && segment != path[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package obs
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SamplingConfig handles the sampling of the log entries below the error level.
+// Entries are sampled by message, or by format for the formatted variants.
+type SamplingConfig struct {
+	// Burst is the number of entries with the same message logged per Period.
+	Burst int
+	// Period is the duration of the sampling window. Defaults to 1 second.
+	Period time.Duration
+	// Thereafter logs one of every Thereafter entries with the same message once the
+	// Burst is exceeded. When zero, they are all discarded.
+	Thereafter int
+}
+
+func (c SamplingConfig) enabled() bool {
+	return c.Burst > 0 || c.Thereafter > 0
+}
+
+// sampler counts the entries logged per message in the current period.
+type sampler struct {
+	config  SamplingConfig
+	dropped atomic.Uint64
+
+	mu     sync.Mutex
+	start  time.Time
+	counts map[string]int
+}
+
+func newSampler(config SamplingConfig) *sampler {
+	if config.Period <= 0 {
+		config.Period = time.Second
+	}
+	return &sampler{config: config, counts: map[string]int{}}
+}
+
+// allow reports whether an entry with the given message is logged.
+func (s *sampler) allow(msg string) bool {
+	now := time.Now()
+	s.mu.Lock()
+	if now.Sub(s.start) >= s.config.Period {
+		s.start = now
+		s.counts = map[string]int{}
+	}
+	s.counts[msg]++
+	n := s.counts[msg]
+	s.mu.Unlock()
+
+	if n <= s.config.Burst {
+		return true
+	}
+	if t := s.config.Thereafter; t > 0 && (n-s.config.Burst)%t == 0 {
+		return true
+	}
+	s.dropped.Add(1)
+	return false
+}
@@ -0,0 +1,29 @@
+package obs
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoggerSampling(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := NewLoggerWithWriter(out).Sample(SamplingConfig{Burst: 2, Thereafter: 3})
+
+	for i := 0; i < 8; i++ {
+		logger.Info("cache miss")
+	}
+	logger.Info("cache hit")
+	logger.Error("cannot save", errors.New("db: timeout"))
+	logger.Error("cannot save", errors.New("db: timeout"))
+
+	// 2 of burst, then the 3rd and 6th of the rest.
+	assert.Equal(t, 4, strings.Count(out.String(), "cache miss"))
+	assert.Equal(t, 1, strings.Count(out.String(), "cache hit"))
+	assert.Equal(t, 2, strings.Count(out.String(), "cannot save"))
+	assert.Equal(t, uint64(4), logger.Dropped())
+	assert.Equal(t, uint64(4), logger.With(String("k", "v")).Dropped())
+}
@@ -0,0 +1,36 @@
+package obs
+
+// Backend identifies a backend initialized by New.
+type Backend string
+
+// Backends initialized by New.
+const (
+	BackendTracing        Backend = "tracing"
+	BackendSentry         Backend = "Sentry"
+	BackendErrorReporting Backend = "GoogleCloudErrorReporting"
+	BackendProfiler       Backend = "GoogleCloudProfiler"
+)
+
+// Policy defines what NewE does when a backend cannot be initialized.
+type Policy int
+
+const (
+	// Required makes NewE fail.
+	Required Policy = iota
+	// BestEffort logs the error and continues without the backend.
+	BestEffort
+)
+
+// BackendStatus describes the state of a backend.
+type BackendStatus struct {
+	Backend Backend
+	// Enabled reports whether the backend is configured.
+	Enabled bool
+	// Err is the initialization error of the backend.
+	Err error
+}
+
+// Live reports whether the backend is enabled and was initialized.
+func (s BackendStatus) Live() bool {
+	return s.Enabled && s.Err == nil
+}
@@ -0,0 +1,38 @@
+package obs
+
+import (
+	"testing"
+
+	"github.com/JoinVerse/obs/errtrack"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestNewE(t *testing.T) {
+	config := Config{
+		NOGCloudEnabled: true,
+		SentryConfig:    errtrack.SentryConfig{SentryDSN: "not a dsn"},
+		TracingConfig:   TracingConfig{SpanExporter: tracetest.NewInMemoryExporter()},
+	}
+	observer, err := NewE(config)
+	assert.Nil(t, observer)
+	assert.ErrorContains(t, err, "cannot start Sentry")
+
+	config.Policies = map[Backend]Policy{BackendSentry: BestEffort}
+	observer, err = NewE(config)
+	assert.Nil(t, err)
+	defer observer.Close()
+
+	status := map[Backend]BackendStatus{}
+	for _, s := range observer.Status() {
+		status[s.Backend] = s
+	}
+	assert.True(t, status[BackendTracing].Live())
+	assert.False(t, status[BackendSentry].Live())
+	assert.NotNil(t, status[BackendSentry].Err)
+	assert.False(t, status[BackendErrorReporting].Enabled)
+	assert.False(t, status[BackendProfiler].Enabled)
+
+	_, err = NewE(Config{NOGCloudEnabled: true, LogLevel: "verbose"})
+	assert.ErrorContains(t, err, `unknown log level "verbose"`)
+}
@@ -0,0 +1,93 @@
+package obs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Tracing exporters.
+const (
+	// TracingOTLP sends the spans to an OpenTelemetry collector using OTLP/HTTP.
+	TracingOTLP = "otlp"
+	// TracingStdout writes the spans to Stdout, useful for local development.
+	TracingStdout = "stdout"
+)
+
+// TracingConfig handles OpenTelemetry tracing configuration.
+type TracingConfig struct {
+	// Exporter selects where the spans are sent: TracingOTLP or TracingStdout.
+	// Tracing is disabled when it is empty and SpanExporter is nil.
+	Exporter string
+	// Endpoint is the host:port of the OTLP/HTTP collector. Defaults to the
+	// OTEL_EXPORTER_OTLP_ENDPOINT environment variable or localhost:4318.
+	Endpoint string
+	// Insecure disables TLS when sending spans to the OTLP collector.
+	Insecure bool
+	// SampleRatio is the fraction, from 0 to 1, of new traces that are sampled. Defaults to 1.
+	// Traces propagated from upstream keep their sampling decision.
+	SampleRatio float64
+	// SpanExporter overrides Exporter, e.g. with tracetest.NewInMemoryExporter() in tests.
+	SpanExporter sdktrace.SpanExporter
+}
+
+func (c TracingConfig) enabled() bool {
+	return c.Exporter != "" || c.SpanExporter != nil
+}
+
+// initTracing sets up the global OpenTelemetry tracer provider and the W3C propagators.
+// The returned function flushes the pending spans and shuts down the provider.
+func initTracing(config TracingConfig, serviceName, serviceVersion string) (func(), error) {
+	exporter := config.SpanExporter
+	if exporter == nil {
+		var err error
+		switch config.Exporter {
+		case TracingOTLP:
+			var opts []otlptracehttp.Option
+			if config.Endpoint != "" {
+				opts = append(opts, otlptracehttp.WithEndpoint(config.Endpoint))
+			}
+			if config.Insecure {
+				opts = append(opts, otlptracehttp.WithInsecure())
+			}
+			exporter, err = otlptracehttp.New(context.Background(), opts...)
+		case TracingStdout:
+			exporter, err = stdouttrace.New()
+		default:
+			err = fmt.Errorf("unknown exporter %q", config.Exporter)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("obs: cannot create span exporter: %w", err)
+		}
+	}
+
+	ratio := config.SampleRatio
+	if ratio <= 0 {
+		ratio = 1
+	}
+	res := resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(serviceVersion),
+	)
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = tp.Shutdown(ctx)
+	}, nil
+}